@@ -0,0 +1,349 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v2"
+
+	"github.com/GlebMoskalev/gitfame/internal/identity"
+	"github.com/GlebMoskalev/gitfame/internal/stats"
+)
+
+const (
+	// envPrefix starts the environment variable of every option, e.g. GITFAME_FORMAT.
+	envPrefix = "GITFAME_"
+	// repoConfigName is looked up in the root of the analyzed repository.
+	repoConfigName = ".gitfame.yaml"
+	sourceDefault  = "default"
+	sourceFlag     = "flag"
+)
+
+// pathOptions are resolved relative to the directory of the config file setting them.
+var pathOptions = map[string]bool{
+	"teams":         true,
+	"template-file": true,
+	"output":        true,
+}
+
+// userOnlyOptions can not be set by the .gitfame.yaml of the analyzed repository, which may
+// come from anyone: they choose which files are read and written and what is passed to git.
+var userOnlyOptions = map[string]bool{
+	"repository":    true,
+	"output":        true,
+	"template-file": true,
+	"revision":      true,
+	"at":            true,
+}
+
+// optionSources records where the value of every option not left at its default came from.
+var optionSources = map[string]string{}
+
+// inlineTeams are the teams of a config file giving "teams" as a mapping instead of a path.
+var inlineTeams []stats.Team
+
+// configLayer is a config file; its keys are flag names. A repository layer is part of
+// the analyzed repository and is not trusted with userOnlyOptions and paths outside of it.
+type configLayer struct {
+	path       string
+	values     yaml.MapSlice
+	repository bool
+}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the gitfame configuration",
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print the effective configuration and where each value comes from",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := loadConfig(cmd.Flags()); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(1)
+		}
+		if err := printConfig(cmd.Flags(), os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	configCmd.AddCommand(configShowCmd)
+	rootCmd.AddCommand(configCmd)
+}
+
+// loadConfig fills the options not given on the command line from GITFAME_* environment
+// variables and the config files: first .gitfame.yaml in the repository root, then the user config.
+func loadConfig(flags *pflag.FlagSet) error {
+	flags.Visit(func(f *pflag.Flag) {
+		optionSources[f.Name] = sourceFlag
+	})
+	if err := applyEnv(flags); err != nil {
+		return err
+	}
+
+	var user configLayer
+	if path := userConfigPath(); path != "" {
+		var err error
+		if user, err = readConfigLayer(path); err != nil {
+			return err
+		}
+	}
+	// The user config may choose the repository, so its userOnlyOptions are applied before
+	// the repository config is looked up; the repository config can not set them anyway.
+	if err := applyConfigLayer(flags, user.only(userOnlyOptions)); err != nil {
+		return err
+	}
+
+	layers := []configLayer{user}
+	if root, err := repositoryRoot(options.repository); err == nil {
+		repo, err := readConfigLayer(filepath.Join(root, repoConfigName))
+		if err != nil {
+			return err
+		}
+		repo.repository = true
+		layers = []configLayer{repo, user}
+	}
+	for _, layer := range layers {
+		if err := applyConfigLayer(flags, layer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// envName returns the environment variable of an option: GITFAME_ and the flag name
+// in upper case with dashes replaced by underscores.
+func envName(flag string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flag, "-", "_"))
+}
+
+func applyEnv(flags *pflag.FlagSet) error {
+	var err error
+	flags.VisitAll(func(f *pflag.Flag) {
+		name := envName(f.Name)
+		value, ok := os.LookupEnv(name)
+		if err != nil || !ok || f.Name == "help" || f.Name == "version" {
+			return
+		}
+		if _, set := optionSources[f.Name]; set {
+			return
+		}
+		// Lists are comma-separated; list flags other than string arrays split them themselves.
+		values := []string{value}
+		if f.Value.Type() == "stringArray" {
+			values = strings.Split(value, ",")
+		}
+		for _, v := range values {
+			if setErr := f.Value.Set(v); setErr != nil {
+				err = fmt.Errorf("%s: %v", name, setErr)
+				return
+			}
+		}
+		optionSources[f.Name] = "env " + name
+	})
+	return err
+}
+
+// userConfigPath returns $XDG_CONFIG_HOME/gitfame/config.yaml, defaulting to ~/.config.
+func userConfigPath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "gitfame", "config.yaml")
+}
+
+func repositoryRoot(path string) (string, error) {
+	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
+	cmd.Dir = path
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// readConfigLayer reads a config file; a missing file is an empty layer.
+func readConfigLayer(path string) (configLayer, error) {
+	layer := configLayer{path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return layer, nil
+	}
+	if err != nil {
+		return layer, fmt.Errorf("failed to read config file: %v", err)
+	}
+	if err := yaml.Unmarshal(data, &layer.values); err != nil {
+		return layer, fmt.Errorf("failed to parse config file %s: %v", path, err)
+	}
+	return layer, nil
+}
+
+// only returns the layer with just the given options.
+func (l configLayer) only(names map[string]bool) configLayer {
+	filtered := l
+	filtered.values = nil
+	for _, item := range l.values {
+		if names[fmt.Sprint(item.Key)] {
+			filtered.values = append(filtered.values, item)
+		}
+	}
+	return filtered
+}
+
+func applyConfigLayer(flags *pflag.FlagSet, layer configLayer) error {
+	for _, item := range layer.values {
+		name := fmt.Sprint(item.Key)
+		flag := flags.Lookup(name)
+		if flag == nil || name == "help" || name == "version" {
+			return fmt.Errorf("%s: unknown option %q", layer.path, name)
+		}
+		if layer.repository && userOnlyOptions[name] {
+			return fmt.Errorf("%s: option %q can not be set in a repository config file, "+
+				"use the user config, the environment or a flag", layer.path, name)
+		}
+		if _, ok := optionSources[name]; ok {
+			continue
+		}
+		if mapping, ok := item.Value.(yaml.MapSlice); ok && name == "teams" {
+			teams, err := configTeams(mapping)
+			if err != nil {
+				return fmt.Errorf("%s: %v", layer.path, err)
+			}
+			inlineTeams = teams
+			optionSources[name] = layer.path
+			continue
+		}
+
+		values, err := configValues(item.Value)
+		if err != nil {
+			return fmt.Errorf("%s: option %q: %v", layer.path, name, err)
+		}
+		if pathOptions[name] {
+			for i, v := range values {
+				if v == "" {
+					continue
+				}
+				if layer.repository && !filepath.IsLocal(v) {
+					return fmt.Errorf("%s: option %q: path %q must be relative and inside the repository",
+						layer.path, name, v)
+				}
+				if !filepath.IsAbs(v) {
+					values[i] = filepath.Join(filepath.Dir(layer.path), v)
+				}
+			}
+		}
+		// Lists are repeated flags for list options and comma-separated values otherwise.
+		if !isList(flag.Value) {
+			values = []string{strings.Join(values, ",")}
+		}
+		for _, v := range values {
+			if err := flag.Value.Set(v); err != nil {
+				return fmt.Errorf("%s: option %q: %v", layer.path, name, err)
+			}
+		}
+		optionSources[name] = layer.path
+	}
+	return nil
+}
+
+// configTeams converts a teams mapping in the format of a teams file.
+func configTeams(mapping yaml.MapSlice) ([]stats.Team, error) {
+	if _, err := identity.ParseTeams(mapping); err != nil {
+		return nil, err
+	}
+	teams := make([]stats.Team, 0, len(mapping))
+	for _, item := range mapping {
+		team := stats.Team{Name: fmt.Sprint(item.Key)}
+		for _, member := range item.Value.([]interface{}) {
+			team.Members = append(team.Members, fmt.Sprint(member))
+		}
+		teams = append(teams, team)
+	}
+	return teams, nil
+}
+
+// isList reports whether a flag is repeatable, taking one value per list item.
+func isList(value pflag.Value) bool {
+	_, ok := value.(pflag.SliceValue)
+	return ok
+}
+
+func configValues(value interface{}) ([]string, error) {
+	switch v := value.(type) {
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if _, ok := item.([]interface{}); ok {
+				return nil, fmt.Errorf("nested lists are not supported")
+			}
+			values = append(values, fmt.Sprint(item))
+		}
+		return values, nil
+	case yaml.MapSlice:
+		return nil, fmt.Errorf("mappings are not supported")
+	case nil:
+		return []string{""}, nil
+	default:
+		return []string{fmt.Sprint(v)}, nil
+	}
+}
+
+// printConfig writes the effective options as YAML, commenting on where non-default
+// values come from.
+func printConfig(flags *pflag.FlagSet, out io.Writer) error {
+	var err error
+	flags.VisitAll(func(f *pflag.Flag) {
+		if err != nil || f.Name == "help" || f.Name == "version" {
+			return
+		}
+		source, ok := optionSources[f.Name]
+		if !ok {
+			source = sourceDefault
+		}
+		var value interface{} = f.Value.String()
+		switch f.Value.Type() {
+		case "bool", "int", "float64":
+			// Scalars are printed as YAML booleans and numbers instead of strings.
+			_ = yaml.Unmarshal([]byte(f.Value.String()), &value)
+		}
+		if isList(f.Value) {
+			value = f.Value.(pflag.SliceValue).GetSlice()
+		}
+		if f.Name == "teams" && inlineTeams != nil {
+			mapping := make(yaml.MapSlice, 0, len(inlineTeams))
+			for _, team := range inlineTeams {
+				mapping = append(mapping, yaml.MapItem{Key: team.Name, Value: team.Members})
+			}
+			value = mapping
+		}
+		data, marshalErr := yaml.Marshal(yaml.MapSlice{{Key: f.Name, Value: value}})
+		if marshalErr != nil {
+			err = marshalErr
+			return
+		}
+		text := strings.TrimSuffix(string(data), "\n")
+		if strings.Contains(text, "\n") {
+			_, err = fmt.Fprintf(out, "# %s\n%s\n", source, text)
+		} else {
+			_, err = fmt.Fprintf(out, "%s # %s\n", text, source)
+		}
+	})
+	return err
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/GlebMoskalev/gitfame/internal/repository"
+)
+
+// listValue is a repeatable flag whose every value is a comma-separated list. Unlike
+// pflag's StringSlice it does not split on commas inside braces, so brace patterns
+// such as '*.{go,mod}' survive.
+type listValue struct {
+	values *[]string
+}
+
+func newListValue(values *[]string) *listValue {
+	return &listValue{values: values}
+}
+
+func (l *listValue) Set(value string) error {
+	*l.values = append(*l.values, repository.SplitList(value)...)
+	return nil
+}
+
+func (l *listValue) Type() string {
+	return "strings"
+}
+
+func (l *listValue) String() string {
+	return strings.Join(*l.values, ",")
+}
+
+// Append, Replace and GetSlice implement pflag.SliceValue.
+
+func (l *listValue) Append(value string) error {
+	*l.values = append(*l.values, value)
+	return nil
+}
+
+func (l *listValue) Replace(values []string) error {
+	*l.values = append([]string(nil), values...)
+	return nil
+}
+
+func (l *listValue) GetSlice() []string {
+	return append([]string{}, *l.values...)
+}
@@ -3,58 +3,147 @@ package main
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/GlebMoskalev/gitfame/internal/stats"
+	"github.com/GlebMoskalev/gitfame/pkg/progressbar"
+	"github.com/GlebMoskalev/gitfame/pkg/report"
 )
 
+const (
+	progressNone = "none"
+	progressBar  = "bar"
+	progressJSON = "json"
+)
+
+// version is set at build time with -ldflags "-X main.version=...".
+var version = "dev"
+
 var (
-	validFormats = map[string]bool{
-		"tabular":    true,
-		"csv":        true,
-		"json":       true,
-		"json-lines": true,
+	validCommitSources = map[string]bool{
+		"blame": true,
+		"log":   true,
 	}
-	validOrderBy = map[string]bool{
-		"lines":   true,
-		"commits": true,
-		"files":   true,
+	validProgress = map[string]bool{
+		progressNone: true,
+		progressBar:  true,
+		progressJSON: true,
+	}
+	validGroupBy = map[string]bool{
+		"author": true,
+		"team":   true,
 	}
 )
 
 type cliOptions struct {
-	repository   string
-	revision     string
-	exclude      string
-	restrictTo   string
-	languages    string
-	extensions   string
-	format       string
-	orderBy      string
-	useCommitter bool
-	showProgress bool
-	measureTime  bool
+	repository     string
+	revision       string
+	at             string
+	exclude        []string
+	restrictTo     []string
+	languages      []string
+	extensions     []string
+	format         string
+	orderBy        string
+	collation      string
+	normalizeNames bool
+	useCommitter   bool
+	progress       string
+	measureTime    bool
+	showEmail      bool
+	showActivity   bool
+	lineAge        bool
+	commitSource   string
+	logFiltered    bool
+	churn          bool
+	since          string
+	until          string
+	showMetadata   bool
+	excludeAuthors string
+	onlyAuthors    string
+	othersBucket   bool
+	teamsFile      string
+	groupBy        string
+	showShare      bool
+	showTotals     bool
+	top            int
+	minLines       int
+	minShare       float64
+	template       string
+	templateFile   string
+	outputs        []string
 }
 
 func init() {
-	rootCmd.Flags().StringVar(&options.repository, "repository", ".", "Path to git repository")
-	rootCmd.Flags().StringVar(&options.revision, "revision", "HEAD", "Git revision to analyze")
-	rootCmd.Flags().StringVar(&options.exclude, "exclude", "", "Exclude files matching pattern")
-	rootCmd.Flags().StringVar(&options.restrictTo, "restrict-to", "", "Restrict analysis to files matching pattern")
-	rootCmd.Flags().StringVar(&options.languages, "languages", "", "Filter by language")
-	rootCmd.Flags().StringVar(&options.extensions, "extensions", "", "Filter by file extensions")
-	rootCmd.Flags().StringVar(&options.format, "format", "tabular", "Output format (tabular, csv, json, json-lines)")
-	rootCmd.Flags().StringVar(&options.orderBy, "order-by", "lines", "Order results by (lines, commits, files)")
-	rootCmd.Flags().BoolVar(&options.useCommitter, "use-committer", false, "Use committer instead of author")
-	rootCmd.Flags().BoolVar(&options.showProgress, "progress", false, "Display progress bar during analysis")
-	rootCmd.Flags().BoolVar(&options.measureTime, "time", false, "Measure and display execution time")
+	rootCmd.PersistentFlags().StringVar(&options.repository, "repository", ".", "Path to git repository")
+	rootCmd.PersistentFlags().StringVar(&options.revision, "revision", "HEAD", "Git revision to analyze")
+	rootCmd.PersistentFlags().StringVar(&options.at, "at", "",
+		"Analyze the last first-parent commit of the revision before date (YYYY-MM-DD or RFC 3339)")
+	rootCmd.PersistentFlags().Var(newListValue(&options.exclude), "exclude",
+		"Exclude files matching glob patterns, e.g. '*.{md,txt}'; repeatable")
+	rootCmd.PersistentFlags().Var(newListValue(&options.restrictTo), "restrict-to",
+		"Restrict analysis to files matching glob patterns; repeatable")
+	rootCmd.PersistentFlags().Var(newListValue(&options.languages), "languages", "Filter by languages; repeatable")
+	rootCmd.PersistentFlags().Var(newListValue(&options.extensions), "extensions",
+		"Filter by file extensions, e.g. '.{go,mod}'; repeatable")
+	rootCmd.PersistentFlags().StringVar(&options.format, "format", "tabular", formatUsage())
+	rootCmd.PersistentFlags().StringArrayVar(&options.outputs, "output", nil,
+		"Write results to file instead of stdout, in the format given by its extension; repeatable")
+	rootCmd.PersistentFlags().StringVar(&options.template, "template", "", "Go text/template for --format=template")
+	rootCmd.PersistentFlags().StringVar(&options.templateFile, "template-file", "", "File with a Go text/template for --format=template")
+	rootCmd.PersistentFlags().StringVar(&options.orderBy, "order-by", "lines",
+		"Order results by comma-separated fields with optional :asc or :desc, e.g. files:desc,name:asc ("+
+			strings.Join(stats.SortFields, ", ")+")")
+	rootCmd.PersistentFlags().StringVar(&options.collation, "collation", stats.DefaultCollation,
+		"Language whose collation orders names and emails, as a BCP 47 tag (e.g. en, de, sv); und is the Unicode default")
+	rootCmd.PersistentFlags().BoolVar(&options.normalizeNames, "normalize-names", false,
+		"Merge contributors whose names differ only in case, accents or Unicode normalization")
+	rootCmd.PersistentFlags().StringVar(&options.excludeAuthors, "exclude-authors", "",
+		"Exclude contributors whose name or email matches glob or /regex/ patterns")
+	rootCmd.PersistentFlags().StringVar(&options.onlyAuthors, "only-authors", "",
+		"Report only contributors whose name or email matches glob or /regex/ patterns")
+	rootCmd.PersistentFlags().BoolVar(&options.othersBucket, "others-bucket", false,
+		"Report lines of filtered out contributors as a single \"others\" contributor")
+	rootCmd.PersistentFlags().StringVar(&options.teamsFile, "teams", "", "YAML file mapping team names to contributor patterns")
+	rootCmd.PersistentFlags().StringVar(&options.groupBy, "group-by", "author", "Aggregate results by (author, team)")
+	rootCmd.PersistentFlags().BoolVar(&options.useCommitter, "use-committer", false, "Use committer instead of author")
+	options.progress = progressNone
+	rootCmd.PersistentFlags().Var(newProgressValue(&options.progress), "progress",
+		"Report progress on stderr as a bar or as JSON events (none, bar, json); "+
+			"the value must be attached with '=', e.g. --progress=json")
+	rootCmd.PersistentFlags().Lookup("progress").NoOptDefVal = progressBar
+	rootCmd.PersistentFlags().BoolVar(&options.measureTime, "time", false, "Measure and display execution time")
+	rootCmd.PersistentFlags().BoolVar(&options.showEmail, "show-email", false, "Add contributor emails to tabular and csv output")
+	rootCmd.PersistentFlags().BoolVar(&options.showActivity, "show-activity", false,
+		"Add first and last contribution dates to tabular and csv output")
+	rootCmd.PersistentFlags().BoolVar(&options.showShare, "share", false, "Add percentage of total lines, commits and files")
+	rootCmd.PersistentFlags().BoolVar(&options.showTotals, "totals", false,
+		"Add a totals row to tabular and csv output and totals to json metadata")
+	rootCmd.PersistentFlags().IntVar(&options.top, "top", 0, "Show only the first N contributors and collapse the rest into \"others\"")
+	rootCmd.PersistentFlags().IntVar(&options.minLines, "min-lines", 0,
+		"Collapse contributors with fewer lines into \"others\"")
+	rootCmd.PersistentFlags().Float64Var(&options.minShare, "min-share", 0,
+		"Collapse contributors with a smaller percentage of lines into \"others\"")
+	rootCmd.PersistentFlags().BoolVar(&options.showMetadata, "metadata", false,
+		"Include revision, resolved commit and snapshot date in the output")
+	rootCmd.PersistentFlags().BoolVar(&options.lineAge, "age", false, "Report line age histogram and median line age per contributor")
+	rootCmd.PersistentFlags().StringVar(&options.commitSource, "commit-source", "blame",
+		"Count commits from surviving lines or from the whole history (blame, log)")
+	rootCmd.PersistentFlags().BoolVar(&options.logFiltered, "log-filtered", false,
+		"Count log commits only for files passing the filters")
+	rootCmd.PersistentFlags().BoolVar(&options.churn, "churn", false, "Report added and deleted lines from git log --numstat")
+	rootCmd.PersistentFlags().StringVar(&options.since, "since", "", "With --churn, only count commits more recent than date")
+	rootCmd.PersistentFlags().StringVar(&options.until, "until", "", "With --churn, only count commits older than date")
 }
 
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 }
@@ -62,44 +151,162 @@ func Execute() {
 var options = &cliOptions{}
 
 var rootCmd = &cobra.Command{
-	Use:   "gitfame",
-	Short: "Calculate git repository statistics",
+	Use:     "gitfame",
+	Short:   "Calculate git repository statistics",
+	Version: version,
 	Run: func(cmd *cobra.Command, args []string) {
+		if err := loadConfig(cmd.Flags()); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(1)
+		}
 		if err := validateOptions(options); err != nil {
-			fmt.Println("Error:", err)
+			fmt.Fprintln(os.Stderr, "Error:", err)
 			os.Exit(1)
 		}
 
+		if options.templateFile != "" {
+			text, err := os.ReadFile(options.templateFile)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "Error: failed to read template file:", err)
+				os.Exit(1)
+			}
+			options.template = string(text)
+		}
+
+		var progress progressbar.Progress
+		if options.progress == progressJSON {
+			progress = progressbar.NewJSON(os.Stderr)
+		}
+
 		var start time.Time
 		if options.measureTime {
 			start = time.Now()
 		}
 
-		stats.CalculateStats(
-			options.repository,
-			options.revision,
-			options.extensions,
-			options.exclude,
-			options.restrictTo,
-			options.languages,
-			options.orderBy,
-			options.format,
-			options.useCommitter,
-			options.showProgress,
-		)
+		err := stats.CalculateStats(stats.Options{
+			RepositoryPath: options.repository,
+			Revision:       options.revision,
+			At:             options.at,
+			Extensions:     options.extensions,
+			Exclude:        options.exclude,
+			RestrictTo:     options.restrictTo,
+			Languages:      options.languages,
+			OrderBy:        options.orderBy,
+			Collation:      options.collation,
+			NormalizeNames: options.normalizeNames,
+			Format:         options.format,
+			UseCommitter:   options.useCommitter,
+			ShowProgress:   options.progress == progressBar,
+			Progress:       progress,
+			ShowEmail:      options.showEmail,
+			ShowActivity:   options.showActivity,
+			LineAge:        options.lineAge,
+			CommitSource:   options.commitSource,
+			LogFiltered:    options.logFiltered,
+			Churn:          options.churn,
+			Since:          options.since,
+			Until:          options.until,
+			ShowMetadata:   options.showMetadata,
+			ExcludeAuthors: options.excludeAuthors,
+			OnlyAuthors:    options.onlyAuthors,
+			OthersBucket:   options.othersBucket,
+			TeamsFile:      options.teamsFile,
+			Teams:          inlineTeams,
+			GroupBy:        options.groupBy,
+			ShowShare:      options.showShare,
+			ShowTotals:     options.showTotals,
+			Top:            options.top,
+			MinLines:       options.minLines,
+			MinShare:       options.minShare,
+			Template:       options.template,
+			Version:        version,
+			Outputs:        options.outputs,
+		})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(1)
+		}
 		if options.measureTime {
-			fmt.Printf("Execution time: %s\n", time.Since(start))
+			fmt.Fprintf(os.Stderr, "Execution time: %s\n", time.Since(start))
 		}
 	},
 }
 
+// formatUsage lists every registered output format with its description.
+func formatUsage() string {
+	var b strings.Builder
+	b.WriteString("Output format:")
+	for _, name := range report.Names() {
+		fmt.Fprintf(&b, "\n  %s - %s", name, report.Describe(name))
+	}
+	return b.String()
+}
+
 func validateOptions(opts *cliOptions) error {
-	if !validFormats[opts.format] {
-		return fmt.Errorf("invalid format: '%s', must be one of: tabular, csv, json, json-lines", opts.format)
+	if _, ok := report.Lookup(opts.format); !ok {
+		return fmt.Errorf("invalid format: '%s', must be one of: %s", opts.format, strings.Join(report.Names(), ", "))
+	}
+
+	if opts.template != "" && opts.templateFile != "" {
+		return fmt.Errorf("--template and --template-file cannot be used together")
+	}
+
+	formats := []string{opts.format}
+	if len(opts.outputs) > 0 {
+		formats = formats[:0]
+		for _, path := range opts.outputs {
+			format := stats.OutputFormat(path, opts.format)
+			if _, ok := report.Lookup(format); !ok {
+				return fmt.Errorf("invalid format '%s' for output %s", format, path)
+			}
+			if info, err := os.Stat(filepath.Dir(path)); err != nil || !info.IsDir() {
+				return fmt.Errorf("directory of output %s does not exist", path)
+			}
+			formats = append(formats, format)
+		}
+	}
+	if slices.Contains(formats, "template") != (opts.template != "" || opts.templateFile != "") {
+		return fmt.Errorf("--format=template requires --template or --template-file, which are only used with it")
+	}
+
+	if !validProgress[opts.progress] {
+		return fmt.Errorf("invalid progress: '%s', must be one of: none, bar, json", opts.progress)
+	}
+
+	if !validCommitSources[opts.commitSource] {
+		return fmt.Errorf("invalid commit-source: '%s', must be one of: blame, log", opts.commitSource)
+	}
+
+	if opts.logFiltered && opts.commitSource != "log" {
+		return fmt.Errorf("--log-filtered requires --commit-source=log")
+	}
+
+	if (opts.since != "" || opts.until != "") && !opts.churn {
+		return fmt.Errorf("--since and --until require --churn")
+	}
+
+	if opts.churn && opts.lineAge {
+		return fmt.Errorf("--churn and --age cannot be used together")
+	}
+
+	if !validGroupBy[opts.groupBy] {
+		return fmt.Errorf("invalid group-by: '%s', must be one of: author, team", opts.groupBy)
+	}
+
+	if opts.groupBy == "team" && opts.teamsFile == "" && inlineTeams == nil {
+		return fmt.Errorf("--group-by=team requires --teams")
+	}
+
+	if opts.top < 0 || opts.minLines < 0 || opts.minShare < 0 || opts.minShare > 100 {
+		return fmt.Errorf("--top and --min-lines must not be negative and --min-share must be between 0 and 100")
+	}
+
+	if _, err := stats.ParseOrderBy(opts.orderBy); err != nil {
+		return err
 	}
 
-	if !validOrderBy[opts.orderBy] {
-		return fmt.Errorf("invalid order-by: '%s', must be one of: lines, commits, files", opts.orderBy)
+	if _, err := stats.ParseCollation(opts.collation); err != nil {
+		return err
 	}
 
 	return nil
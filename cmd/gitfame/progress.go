@@ -0,0 +1,32 @@
+package main
+
+// progressAliases keep the boolean values of --progress from before it took a mode working.
+var progressAliases = map[string]string{
+	"true":  progressBar,
+	"false": progressNone,
+}
+
+// progressValue is the value of --progress, accepting the progressAliases.
+type progressValue struct {
+	value *string
+}
+
+func newProgressValue(value *string) *progressValue {
+	return &progressValue{value: value}
+}
+
+func (p *progressValue) Set(value string) error {
+	if alias, ok := progressAliases[value]; ok {
+		value = alias
+	}
+	*p.value = value
+	return nil
+}
+
+func (p *progressValue) Type() string {
+	return "string"
+}
+
+func (p *progressValue) String() string {
+	return *p.value
+}
@@ -0,0 +1,64 @@
+package blame
+
+import (
+	"sort"
+	"time"
+
+	"github.com/GlebMoskalev/gitfame/pkg/report"
+)
+
+const day = 24 * time.Hour
+
+type agedLines struct {
+	age   time.Duration
+	lines int
+}
+
+func computeLineAge(commits []*ContributorStats, reference time.Time) *report.LineAge {
+	result := &report.LineAge{}
+	aged := make([]agedLines, 0, len(commits))
+	total := 0
+	for _, c := range commits {
+		if c.Lines == 0 {
+			continue
+		}
+		age := reference.Sub(c.FirstContribution)
+		if age < 0 {
+			age = 0
+		}
+		aged = append(aged, agedLines{age: age, lines: c.Lines})
+		total += c.Lines
+		addToBucket(result, age, c.Lines)
+	}
+	if total == 0 {
+		return result
+	}
+
+	sort.Slice(aged, func(i, j int) bool {
+		return aged[i].age < aged[j].age
+	})
+	median := (total - 1) / 2
+	for _, a := range aged {
+		if median < a.lines {
+			result.MedianDays = int(a.age / day)
+			break
+		}
+		median -= a.lines
+	}
+	return result
+}
+
+func addToBucket(a *report.LineAge, age time.Duration, lines int) {
+	switch {
+	case age < 30*day:
+		a.UnderMonth += lines
+	case age < 182*day:
+		a.UnderHalfYear += lines
+	case age < 365*day:
+		a.UnderYear += lines
+	case age < 3*365*day:
+		a.UnderThreeYears += lines
+	default:
+		a.Older += lines
+	}
+}
@@ -2,30 +2,63 @@ package blame
 
 import (
 	"fmt"
+	"os"
 	"os/exec"
 	"regexp"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/GlebMoskalev/gitfame/internal/identity"
 	"github.com/GlebMoskalev/gitfame/internal/repository"
 	"github.com/GlebMoskalev/gitfame/pkg/progressbar"
+	"github.com/GlebMoskalev/gitfame/pkg/report"
 )
 
+// ContributorStats is a report contributor together with the per-commit data it was
+// aggregated from.
 type ContributorStats struct {
-	Name    string `json:"name"`
-	Lines   int    `json:"lines"`
-	Commits int    `json:"commits"`
-	Files   int    `json:"files"`
+	report.Contributor
+
+	boundary bool
+	// commits and fileSet hold the per-commit records and files an aggregated contributor
+	// was built from, so that contributors can be merged without double counting.
+	commits      []*ContributorStats
+	fileSet      map[string]struct{}
+	ageReference time.Time
+}
+
+type Options struct {
+	UseCommitter     bool
+	LineAge          bool
+	CommitSource     string
+	LogFilteredPaths bool
+	Since            string
+	Until            string
+	// Identity maps the name and email of a commit to the contributor it is reported under;
+	// commits for which it returns false are dropped. Nil reports commits under their name.
+	Identity IdentityFunc
+	// NormalizeNames passes Identity a single spelling for names differing only in case,
+	// accents or Unicode representation, see identity.Spellings.
+	NormalizeNames bool
 }
 
+type IdentityFunc func(name, email string) (string, bool)
+
 var (
 	regHashCommitLog     = regexp.MustCompile(`^commit\s\S{40}$`)
 	regAuthorLog         = regexp.MustCompile(`^Author:\s(.*)$`)
+	regDateLog           = regexp.MustCompile(`^Date:\s+(\d+)$`)
 	regHashAndLineCommit = regexp.MustCompile(`^\S{40}\s\d+\s\d+\s\d+$`)
 	regAuthor            = regexp.MustCompile(`^author\s(.+)$`)
+	regAuthorMail        = regexp.MustCompile(`^author-mail\s<(.*)>$`)
+	regAuthorTime        = regexp.MustCompile(`^author-time\s(\d+)$`)
 	regCommitter         = regexp.MustCompile(`^committer\s(.+)$`)
+	regCommitterMail     = regexp.MustCompile(`^committer-mail\s<(.*)>$`)
+	regCommitterTime     = regexp.MustCompile(`^committer-time\s(\d+)$`)
 )
 
 type fileError struct {
@@ -33,16 +66,18 @@ type fileError struct {
 	err  error
 }
 
-func GetContributorStats(rs *repository.Snapshot, useCommitter bool, bar *progressbar.ProgressBar) []*ContributorStats {
+// GetContributorStats blames every file of the snapshot and returns the contributors
+// together with the files that could not be blamed.
+func GetContributorStats(rs *repository.Snapshot, opts Options,
+	progress progressbar.Progress) ([]*ContributorStats, []string) {
 	commitStatsMap := make(map[string]*ContributorStats)
-	contributorFilesMap := make(map[string]map[string]struct{})
+	commitFilesMap := make(map[string]map[string]struct{})
 	var mu sync.Mutex
 	var wg sync.WaitGroup
 	errChan := make(chan fileError, len(rs.Files))
 	semaphore := make(chan struct{}, runtime.NumCPU())
-	if bar != nil {
-		bar.Total(len(rs.Files))
-		defer bar.Close()
+	if progress != nil {
+		progress.StartPhase("blaming", len(rs.Files))
 	}
 
 	for _, file := range rs.Files {
@@ -51,49 +86,75 @@ func GetContributorStats(rs *repository.Snapshot, useCommitter bool, bar *progre
 		go func(f string) {
 			defer wg.Done()
 			defer func() { <-semaphore }()
-			err := processFile(rs, file, commitStatsMap, contributorFilesMap, useCommitter, &mu)
+			start := time.Now()
+			lines, err := processFile(rs, f, commitStatsMap, commitFilesMap, opts.UseCommitter, &mu)
 			if err != nil {
 				errChan <- fileError{
-					file: file,
+					file: f,
 					err:  err,
 				}
 			}
-			if bar != nil {
-				bar.Tick()
+			if progress != nil {
+				progress.FileDone(progressbar.FileEvent{File: f, Lines: lines, Duration: time.Since(start), Err: err})
 			}
 		}(file)
 	}
 
 	wg.Wait()
+	if progress != nil {
+		progress.StartPhase("aggregating", 0)
+	}
 	close(errChan)
+	skipped := make([]string, 0)
 	for e := range errChan {
-		fmt.Printf("Error in file %q: %v\n", e.file, e.err)
+		fmt.Fprintf(os.Stderr, "Warning: skipping file %q: %v\n", e.file, e.err)
+		skipped = append(skipped, e.file)
 	}
+	sort.Strings(skipped)
 
-	result := aggregateResults(commitStatsMap, contributorFilesMap)
-	return result
+	identityFunc := normalizedIdentity(opts, commitStatsMap)
+	result := aggregateResults(commitStatsMap, commitFilesMap, identityFunc)
+	if opts.LineAge {
+		addLineAge(result, rs.CommitTime)
+	}
+	if opts.CommitSource == CommitSourceLog {
+		authors, err := countLogCommits(rs, opts.UseCommitter, opts.LogFilteredPaths, identityFunc)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to count log commits: %v\n", err)
+		} else {
+			result = applyLogCommits(result, authors)
+		}
+	}
+	return result, skipped
 }
 
+// processFile blames file into the maps and returns the number of lines it has.
 func processFile(rs *repository.Snapshot, file string, commitStatsMap map[string]*ContributorStats,
-	contributorFilesMap map[string]map[string]struct{}, useCommitter bool, mu *sync.Mutex) error {
-	cmd := exec.Command("git", "blame", "--porcelain", rs.Revision, file)
+	commitFilesMap map[string]map[string]struct{}, useCommitter bool, mu *sync.Mutex) (int, error) {
+	cmd := exec.Command("git", "blame", "--porcelain", rs.RevisionRange(), "--", file)
 	cmd.Dir = rs.GitRootDir
 	out, err := cmd.Output()
 	if err != nil {
-		return fmt.Errorf("git blame failed for %s: %v", file, err)
+		return 0, fmt.Errorf("git blame failed for %s: %v", file, err)
 	}
 
 	if len(out) == 0 {
-		return processEmptyFile(rs, file, commitStatsMap, contributorFilesMap, mu)
+		return 0, processEmptyFile(rs, file, commitStatsMap, commitFilesMap, mu)
 	}
 	lines := strings.Split(string(out), "\n")
-	return processBlameOutput(lines, commitStatsMap, contributorFilesMap, file, useCommitter, mu)
-
+	// Porcelain output prefixes every line of the file content with a tab.
+	count := 0
+	for _, line := range lines {
+		if strings.HasPrefix(line, "\t") {
+			count++
+		}
+	}
+	return count, processBlameOutput(lines, commitStatsMap, commitFilesMap, file, useCommitter, rs.Boundary != "", mu)
 }
 
 func processEmptyFile(rs *repository.Snapshot, file string, commitStatsMap map[string]*ContributorStats,
-	contributorFilesMap map[string]map[string]struct{}, mu *sync.Mutex) error {
-	cmd := exec.Command("git", "log", rs.Revision, "--", file)
+	commitFilesMap map[string]map[string]struct{}, mu *sync.Mutex) error {
+	cmd := exec.Command("git", "log", "--date=unix", rs.RevisionRange(), "--", file)
 	cmd.Dir = rs.GitRootDir
 	out, err := cmd.Output()
 
@@ -108,16 +169,17 @@ func processEmptyFile(rs *repository.Snapshot, file string, commitStatsMap map[s
 	commit := strings.Split(linesSplit[0], " ")[1]
 	authorLine := linesSplit[1][len("Author: "):]
 	author := extractName(authorLine)
+	var commitTime time.Time
+	if match := regDateLog.FindStringSubmatch(linesSplit[2]); match != nil {
+		commitTime = parseUnixTime(match[1])
+	}
 
 	mu.Lock()
 	defer mu.Unlock()
 	if _, ok := commitStatsMap[commit]; !ok {
-		commitStatsMap[commit] = &ContributorStats{
-			Name:  author,
-			Lines: 0,
-		}
+		commitStatsMap[commit] = newCommitStats(author, extractEmail(authorLine), commitTime)
 	}
-	ensureContributorFilesMap(contributorFilesMap, author, file)
+	ensureCommitFilesMap(commitFilesMap, commit, file)
 	return nil
 
 }
@@ -129,8 +191,38 @@ func extractName(authorLine string) string {
 	return strings.TrimSpace(authorLine)
 }
 
+func extractEmail(authorLine string) string {
+	start := strings.Index(authorLine, "<")
+	end := strings.LastIndex(authorLine, ">")
+	if start < 0 || end <= start {
+		return ""
+	}
+	return authorLine[start+1 : end]
+}
+
+func parseUnixTime(s string) time.Time {
+	seconds, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(seconds, 0).UTC()
+}
+
+func newCommitStats(name, email string, commitTime time.Time) *ContributorStats {
+	stats := &ContributorStats{Contributor: report.Contributor{
+		Name:              name,
+		FirstContribution: commitTime,
+		LastContribution:  commitTime,
+	}}
+	if email != "" {
+		stats.Emails = []string{email}
+	}
+	return stats
+}
+
 func processBlameOutput(lines []string, commitStatsMap map[string]*ContributorStats,
-	contributorFilesMap map[string]map[string]struct{}, file string, useCommitter bool, mu *sync.Mutex) error {
+	commitFilesMap map[string]map[string]struct{}, file string, useCommitter, skipBoundary bool,
+	mu *sync.Mutex) error {
 	for i := 0; i < len(lines); i++ {
 		if !regHashAndLineCommit.MatchString(lines[i]) {
 			continue
@@ -144,16 +236,23 @@ func processBlameOutput(lines []string, commitStatsMap map[string]*ContributorSt
 		if !ok {
 			stats = findAuthorInfo(lines, i, commit, commitStatsMap, useCommitter)
 			if stats == nil {
+				mu.Unlock()
 				continue
 			}
 			i = findNextHashLine(lines, i+1) - 1
 		}
+		// Lines older than the start of a revision range are blamed on the boundary commit.
+		if skipBoundary && stats.boundary {
+			mu.Unlock()
+			continue
+		}
 		lineCount, err := strconv.Atoi(lineSplit[3])
 		if err != nil {
-			return fmt.Errorf("failed parse line count from %q: %v", lineCount, err)
+			mu.Unlock()
+			return fmt.Errorf("failed parse line count from %q: %v", lineSplit[3], err)
 		}
 		stats.Lines += lineCount
-		ensureContributorFilesMap(contributorFilesMap, stats.Name, file)
+		ensureCommitFilesMap(commitFilesMap, commit, file)
 		mu.Unlock()
 	}
 	return nil
@@ -162,26 +261,41 @@ func processBlameOutput(lines []string, commitStatsMap map[string]*ContributorSt
 
 func findAuthorInfo(lines []string, startIdx int, commit string,
 	commitStatsMap map[string]*ContributorStats, useCommitter bool) *ContributorStats {
-	nameRegex := regAuthor
+	nameRegex, mailRegex, timeRegex := regAuthor, regAuthorMail, regAuthorTime
 	if useCommitter {
-		nameRegex = regCommitter
+		nameRegex, mailRegex, timeRegex = regCommitter, regCommitterMail, regCommitterTime
 	}
 
-	for i := startIdx; i < len(lines); i++ {
+	var name, email string
+	var commitTime time.Time
+	found, boundary := false, false
+	for i := startIdx + 1; i < len(lines); i++ {
+		if strings.HasPrefix(lines[i], "\t") || regHashAndLineCommit.MatchString(lines[i]) {
+			break
+		}
 		if nameRegex.MatchString(lines[i]) {
 			lineSplit := strings.Split(lines[i], " ")
 			if len(lineSplit) < 1 {
 				continue
 			}
-			name := strings.Join(lineSplit[1:], " ")
-			stats := &ContributorStats{
-				Name: name,
-			}
-			commitStatsMap[commit] = stats
-			return stats
+			name = strings.Join(lineSplit[1:], " ")
+			found = true
+		} else if match := mailRegex.FindStringSubmatch(lines[i]); match != nil {
+			email = match[1]
+		} else if match := timeRegex.FindStringSubmatch(lines[i]); match != nil {
+			commitTime = parseUnixTime(match[1])
+		} else if lines[i] == "boundary" {
+			boundary = true
 		}
 	}
-	return nil
+	if !found {
+		return nil
+	}
+
+	stats := newCommitStats(name, email, commitTime)
+	stats.boundary = boundary
+	commitStatsMap[commit] = stats
+	return stats
 
 }
 
@@ -194,35 +308,129 @@ func findNextHashLine(lines []string, startIndex int) int {
 	return len(lines)
 }
 
-func ensureContributorFilesMap(contributorFilesMap map[string]map[string]struct{}, contributor, file string) {
-	if contributorFilesMap[contributor] == nil {
-		contributorFilesMap[contributor] = make(map[string]struct{})
+func ensureCommitFilesMap(commitFilesMap map[string]map[string]struct{}, commit, file string) {
+	if commitFilesMap[commit] == nil {
+		commitFilesMap[commit] = make(map[string]struct{})
 	}
-	contributorFilesMap[contributor][file] = struct{}{}
+	commitFilesMap[commit][file] = struct{}{}
 }
 
 func aggregateResults(commitStatsMap map[string]*ContributorStats,
-	contributorFilesMap map[string]map[string]struct{}) []*ContributorStats {
+	commitFilesMap map[string]map[string]struct{}, identity IdentityFunc) []*ContributorStats {
 	aggregated := make(map[string]*ContributorStats)
-	for _, s := range commitStatsMap {
-		stats, ok := aggregated[s.Name]
+	contributorEmailsMap := make(map[string]map[string]struct{})
+	contributorFilesMap := make(map[string]map[string]struct{})
+	for commit, s := range commitStatsMap {
+		// Boundary commits of a revision range only own lines introduced before the range.
+		if s.boundary && s.Lines == 0 {
+			continue
+		}
+		key, ok := identityKey(identity, s)
 		if !ok {
-			stats = &ContributorStats{
-				Name:    s.Name,
-				Lines:   0,
-				Commits: 0,
+			continue
+		}
+		stats, ok := aggregated[key]
+		if !ok {
+			stats = &ContributorStats{Contributor: report.Contributor{
+				Name:              key,
+				Lines:             0,
+				Commits:           0,
+				FirstContribution: s.FirstContribution,
+				LastContribution:  s.LastContribution,
+			}}
+			if s.Churn != nil {
+				stats.Churn = &report.LineChurn{}
 			}
-			aggregated[s.Name] = stats
+			aggregated[key] = stats
+			contributorEmailsMap[key] = make(map[string]struct{})
+			contributorFilesMap[key] = make(map[string]struct{})
 		}
 		stats.Lines += s.Lines
 		stats.Commits += 1
+		stats.commits = append(stats.commits, s)
+		for _, email := range s.Emails {
+			contributorEmailsMap[key][email] = struct{}{}
+		}
+		for file := range commitFilesMap[commit] {
+			contributorFilesMap[key][file] = struct{}{}
+		}
+		stats.FirstContribution = earliest(stats.FirstContribution, s.FirstContribution)
+		stats.LastContribution = latest(stats.LastContribution, s.LastContribution)
+		if s.Churn != nil {
+			stats.Churn.Added += s.Churn.Added
+			stats.Churn.Deleted += s.Churn.Deleted
+		}
 	}
 
 	result := make([]*ContributorStats, 0, len(aggregated))
 	for _, entry := range aggregated {
-		entry.Files = len(contributorFilesMap[entry.Name])
+		entry.fileSet = contributorFilesMap[entry.Name]
+		entry.Files = len(entry.fileSet)
+		entry.Emails = sortedKeys(contributorEmailsMap[entry.Name])
 		result = append(result, entry)
 	}
 	return result
 
 }
+
+// normalizedIdentity returns opts.Identity, applied with NormalizeNames to the spelling
+// chosen for the name of a commit among the spellings of the commits.
+func normalizedIdentity(opts Options, commitStatsMap map[string]*ContributorStats) IdentityFunc {
+	if !opts.NormalizeNames {
+		return opts.Identity
+	}
+	spellings := identity.NewSpellings()
+	for _, s := range commitStatsMap {
+		if !s.boundary || s.Lines > 0 {
+			spellings.Add(s.Name, s.Lines)
+		}
+	}
+	return func(name, email string) (string, bool) {
+		name = spellings.Canonical(name)
+		if opts.Identity == nil {
+			return name, true
+		}
+		return opts.Identity(name, email)
+	}
+}
+
+func identityKey(identity IdentityFunc, s *ContributorStats) (string, bool) {
+	if identity == nil {
+		return s.Name, true
+	}
+	email := ""
+	if len(s.Emails) > 0 {
+		email = s.Emails[0]
+	}
+	return identity(s.Name, email)
+}
+
+func addLineAge(contributors []*ContributorStats, reference time.Time) {
+	for _, c := range contributors {
+		c.ageReference = reference
+		c.Age = computeLineAge(c.commits, reference)
+	}
+}
+
+func earliest(a, b time.Time) time.Time {
+	if a.IsZero() || (!b.IsZero() && b.Before(a)) {
+		return b
+	}
+	return a
+}
+
+func latest(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
+
+func sortedKeys(set map[string]struct{}) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
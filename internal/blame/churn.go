@@ -0,0 +1,104 @@
+package blame
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/GlebMoskalev/gitfame/internal/repository"
+	"github.com/GlebMoskalev/gitfame/pkg/report"
+)
+
+type churnCommit struct {
+	hash    string
+	stats   *ContributorStats
+	touched bool
+}
+
+// GetChurnStats walks git log --numstat of the snapshot revision and sums added and
+// deleted lines per contributor for files passing the snapshot filters.
+func GetChurnStats(rs *repository.Snapshot, opts Options) ([]*ContributorStats, error) {
+	format := "%x00%H%x00%an%x00%ae%x00%at"
+	if opts.UseCommitter {
+		format = "%x00%H%x00%cn%x00%ce%x00%ct"
+	}
+	args := []string{"-c", "core.quotepath=off", "log", "--numstat", "--no-renames", "--format=" + format, rs.RevisionRange()}
+	if opts.Since != "" {
+		args = append(args, "--since="+opts.Since)
+	}
+	if opts.Until != "" {
+		args = append(args, "--until="+opts.Until)
+	}
+	cmd := exec.Command("git", args...)
+	cmd.Dir = rs.GitRootDir
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git log failed: %v", err)
+	}
+
+	commitFilesMap := make(map[string]map[string]struct{})
+	var commits []*churnCommit
+	var commit *churnCommit
+	for _, line := range strings.Split(string(out), "\n") {
+		if strings.HasPrefix(line, "\x00") {
+			commit, err = parseChurnHeader(line)
+			if err != nil {
+				return nil, err
+			}
+			commits = append(commits, commit)
+			continue
+		}
+		if line == "" || commit == nil {
+			continue
+		}
+		if err := commit.addNumstat(line, rs.Filters, commitFilesMap); err != nil {
+			return nil, err
+		}
+	}
+
+	// Commits touching only filtered out files are not counted.
+	commitStatsMap := make(map[string]*ContributorStats)
+	for _, c := range commits {
+		if c.touched {
+			commitStatsMap[c.hash] = c.stats
+		}
+	}
+
+	return aggregateResults(commitStatsMap, commitFilesMap, normalizedIdentity(opts, commitStatsMap)), nil
+}
+
+func parseChurnHeader(line string) (*churnCommit, error) {
+	fields := strings.Split(line[1:], "\x00")
+	if len(fields) != 4 {
+		return nil, fmt.Errorf("failed parse commit header %q", line)
+	}
+	stats := newCommitStats(fields[1], fields[2], parseUnixTime(fields[3]))
+	stats.Churn = &report.LineChurn{}
+	return &churnCommit{
+		hash:  fields[0],
+		stats: stats,
+	}, nil
+}
+
+func (c *churnCommit) addNumstat(line string, filters repository.Filters,
+	commitFilesMap map[string]map[string]struct{}) error {
+	fields := strings.SplitN(line, "\t", 3)
+	if len(fields) != 3 {
+		return fmt.Errorf("failed parse numstat line %q", line)
+	}
+	file := fields[2]
+	if !filters.Match(file) {
+		return nil
+	}
+
+	// Binary files are reported as "-" and only count as touched.
+	added, _ := strconv.Atoi(fields[0])
+	deleted, _ := strconv.Atoi(fields[1])
+	c.stats.Churn.Added += added
+	c.stats.Churn.Deleted += deleted
+	c.stats.Lines += added + deleted
+	c.touched = true
+	ensureCommitFilesMap(commitFilesMap, c.hash, file)
+	return nil
+}
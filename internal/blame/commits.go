@@ -0,0 +1,92 @@
+package blame
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/GlebMoskalev/gitfame/internal/repository"
+	"github.com/GlebMoskalev/gitfame/pkg/report"
+)
+
+const (
+	CommitSourceBlame = "blame"
+	CommitSourceLog   = "log"
+)
+
+type logAuthor struct {
+	commits int
+	emails  map[string]struct{}
+}
+
+func countLogCommits(rs *repository.Snapshot, useCommitter, filteredPaths bool,
+	identity IdentityFunc) (map[string]*logAuthor, error) {
+	format := "%an%x00%ae"
+	if useCommitter {
+		format = "%cn%x00%ce"
+	}
+	authors := make(map[string]*logAuthor)
+	if filteredPaths && len(rs.Files) == 0 {
+		return authors, nil
+	}
+
+	args := []string{"log", "--format=" + format, rs.RevisionRange()}
+	var stdin string
+	if filteredPaths {
+		args = append(args, "--stdin")
+		stdin = "--\n" + strings.Join(rs.Files, "\n") + "\n"
+	}
+	cmd := exec.Command("git", args...)
+	cmd.Dir = rs.GitRootDir
+	cmd.Stdin = strings.NewReader(stdin)
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git log failed: %v", err)
+	}
+
+	for _, line := range strings.Split(string(out), "\n") {
+		if line == "" {
+			continue
+		}
+		name, email, _ := strings.Cut(line, "\x00")
+		if identity != nil {
+			var ok bool
+			if name, ok = identity(name, email); !ok {
+				continue
+			}
+		}
+		author, ok := authors[name]
+		if !ok {
+			author = &logAuthor{emails: make(map[string]struct{})}
+			authors[name] = author
+		}
+		author.commits++
+		if email != "" {
+			author.emails[email] = struct{}{}
+		}
+	}
+	return authors, nil
+}
+
+func applyLogCommits(contributors []*ContributorStats, authors map[string]*logAuthor) []*ContributorStats {
+	seen := make(map[string]struct{}, len(contributors))
+	for _, c := range contributors {
+		seen[c.Name] = struct{}{}
+		c.SurvivingCommits = c.Commits
+		c.Commits = 0
+		if author, ok := authors[c.Name]; ok {
+			c.Commits = author.commits
+		}
+	}
+	for name, author := range authors {
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		contributors = append(contributors, &ContributorStats{Contributor: report.Contributor{
+			Name:    name,
+			Commits: author.commits,
+			Emails:  sortedKeys(author.emails),
+		}})
+	}
+	return contributors
+}
@@ -0,0 +1,68 @@
+package blame
+
+import (
+	"math"
+
+	"github.com/GlebMoskalev/gitfame/pkg/report"
+)
+
+// Merge combines contributors into a single contributor with the given name. Lines and
+// commits are summed, while files and emails are counted once, so merging every
+// contributor of a report yields its totals.
+func Merge(name string, contributors []*ContributorStats) *ContributorStats {
+	merged := &ContributorStats{
+		Contributor: report.Contributor{Name: name},
+		fileSet:     make(map[string]struct{}),
+	}
+	emails := make(map[string]struct{})
+	withAge := false
+	for _, c := range contributors {
+		merged.Lines += c.Lines
+		merged.Commits += c.Commits
+		merged.SurvivingCommits += c.SurvivingCommits
+		merged.commits = append(merged.commits, c.commits...)
+		for file := range c.fileSet {
+			merged.fileSet[file] = struct{}{}
+		}
+		for _, email := range c.Emails {
+			emails[email] = struct{}{}
+		}
+		merged.FirstContribution = earliest(merged.FirstContribution, c.FirstContribution)
+		merged.LastContribution = latest(merged.LastContribution, c.LastContribution)
+		if c.Churn != nil {
+			if merged.Churn == nil {
+				merged.Churn = &report.LineChurn{}
+			}
+			merged.Churn.Added += c.Churn.Added
+			merged.Churn.Deleted += c.Churn.Deleted
+		}
+		if c.Age != nil {
+			withAge = true
+			merged.ageReference = c.ageReference
+		}
+	}
+	merged.Files = len(merged.fileSet)
+	merged.Emails = sortedKeys(emails)
+	if withAge {
+		merged.Age = computeLineAge(merged.commits, merged.ageReference)
+	}
+	return merged
+}
+
+// AddShares sets the share of every contributor relative to total.
+func AddShares(contributors []*ContributorStats, total *ContributorStats) {
+	for _, c := range contributors {
+		c.Share = &report.Share{
+			Lines:   percent(c.Lines, total.Lines),
+			Commits: percent(c.Commits, total.Commits),
+			Files:   percent(c.Files, total.Files),
+		}
+	}
+}
+
+func percent(value, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(value)*10000/float64(total)) / 100
+}
@@ -0,0 +1,137 @@
+package identity
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// OthersName is the contributor name excluded authors are reported under when bucketed.
+const OthersName = "others"
+
+// Pattern matches a contributor name or email. Patterns wrapped in slashes ("/bot$/") are
+// regular expressions, anything else is a glob as understood by filepath.Match.
+type Pattern struct {
+	glob string
+	re   *regexp.Regexp
+}
+
+func ParsePattern(s string) (Pattern, error) {
+	if strings.HasPrefix(s, "/") {
+		if len(s) < 2 || !strings.HasSuffix(s, "/") {
+			return Pattern{}, fmt.Errorf("invalid author pattern %q: unterminated regular expression", s)
+		}
+		re, err := regexp.Compile(s[1 : len(s)-1])
+		if err != nil {
+			return Pattern{}, fmt.Errorf("invalid author pattern %q: %v", s, err)
+		}
+		return Pattern{re: re}, nil
+	}
+	if _, err := filepath.Match(s, ""); err != nil {
+		return Pattern{}, fmt.Errorf("invalid author pattern %q: %v", s, err)
+	}
+	return Pattern{glob: s}, nil
+}
+
+// SplitPatterns splits a comma-separated list of patterns. Commas inside a /regex/ or
+// inside braces do not separate patterns, so "/^[a-z]{2,}\[bot\]$/,ci@*" is two patterns.
+func SplitPatterns(s string) ([]string, error) {
+	var patterns []string
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '/':
+			// Only a slash starting a pattern, after any spaces, opens a regular expression.
+			if strings.TrimSpace(s[start:i]) != "" {
+				continue
+			}
+			end := i + 1
+			for ; end < len(s) && s[end] != '/'; end++ {
+				if s[end] == '\\' {
+					end++
+				}
+			}
+			if end >= len(s) {
+				return nil, fmt.Errorf("invalid author pattern %q: unterminated regular expression", s[start:])
+			}
+			i = end
+		case '{':
+			depth++
+		case '}':
+			if depth > 0 {
+				depth--
+			}
+		case ',':
+			if depth == 0 {
+				patterns = appendPattern(patterns, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return appendPattern(patterns, s[start:]), nil
+}
+
+func appendPattern(patterns []string, pattern string) []string {
+	if pattern = strings.TrimSpace(pattern); pattern != "" {
+		patterns = append(patterns, pattern)
+	}
+	return patterns
+}
+
+func ParsePatterns(patterns []string) ([]Pattern, error) {
+	result := make([]Pattern, 0, len(patterns))
+	for _, p := range patterns {
+		pattern, err := ParsePattern(p)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, pattern)
+	}
+	return result, nil
+}
+
+func (p Pattern) MatchString(s string) bool {
+	if p.re != nil {
+		return p.re.MatchString(s)
+	}
+	matched, _ := filepath.Match(p.glob, s)
+	return matched
+}
+
+// Match reports whether the pattern matches the name or the email.
+func (p Pattern) Match(name, email string) bool {
+	return p.MatchString(name) || (email != "" && p.MatchString(email))
+}
+
+func matchesAny(patterns []Pattern, name, email string) bool {
+	for _, p := range patterns {
+		if p.Match(name, email) {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter decides which authors are reported, based on --exclude-authors and --only-authors.
+type Filter struct {
+	Exclude      []Pattern
+	Only         []Pattern
+	OthersBucket bool
+}
+
+// Key returns the name a commit by name and email is aggregated under, and false if the
+// commit is dropped from the results.
+func (f *Filter) Key(name, email string) (string, bool) {
+	excluded := matchesAny(f.Exclude, name, email) ||
+		(len(f.Only) > 0 && !matchesAny(f.Only, name, email))
+	if !excluded {
+		return name, true
+	}
+	if f.OthersBucket {
+		return OthersName, true
+	}
+	return "", false
+}
@@ -0,0 +1,75 @@
+package identity
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// NormalizeName returns the form of a contributor name used to recognize spellings of the
+// same person: compatibility-decomposed, without combining marks, case-folded and with
+// runs of whitespace collapsed, so "José  García" and "JOSE GARCIA" both become "jose garcia".
+func NormalizeName(name string) string {
+	t := transform.Chain(norm.NFKD, runes.Remove(runes.In(unicode.Mn)), cases.Fold(), norm.NFC)
+	normalized, _, err := transform.String(t, name)
+	if err != nil {
+		normalized = name
+	}
+	return strings.Join(strings.Fields(normalized), " ")
+}
+
+// Spellings picks one spelling for all names that are equal after NormalizeName: the one
+// with the most lines, then the most commits, in Unicode normalization form C.
+type Spellings struct {
+	counts map[string]map[string]*spellingCount
+	chosen map[string]string
+}
+
+type spellingCount struct {
+	lines, commits int
+}
+
+func NewSpellings() *Spellings {
+	return &Spellings{
+		counts: make(map[string]map[string]*spellingCount),
+		chosen: make(map[string]string),
+	}
+}
+
+// Add records a commit with the given number of lines under a spelling of a name. It has
+// no effect on names whose spelling has already been chosen by Canonical.
+func (s *Spellings) Add(name string, lines int) {
+	key := NormalizeName(name)
+	if s.counts[key] == nil {
+		s.counts[key] = make(map[string]*spellingCount)
+	}
+	count, ok := s.counts[key][name]
+	if !ok {
+		count = &spellingCount{}
+		s.counts[key][name] = count
+	}
+	count.lines += lines
+	count.commits++
+}
+
+// Canonical returns the spelling name is reported under. A name never added is its own
+// spelling, and that of the names equal to it looked up later.
+func (s *Spellings) Canonical(name string) string {
+	key := NormalizeName(name)
+	if chosen, ok := s.chosen[key]; ok {
+		return chosen
+	}
+	best, bestCount := name, &spellingCount{}
+	for spelling, count := range s.counts[key] {
+		if count.lines > bestCount.lines || count.lines == bestCount.lines &&
+			(count.commits > bestCount.commits || count.commits == bestCount.commits && spelling < best) {
+			best, bestCount = spelling, count
+		}
+	}
+	s.chosen[key] = norm.NFC.String(best)
+	return s.chosen[key]
+}
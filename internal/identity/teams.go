@@ -0,0 +1,84 @@
+package identity
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v2"
+)
+
+// UnassignedTeam is the team of contributors not matched by any team pattern.
+const UnassignedTeam = "unassigned"
+
+type Team struct {
+	Name     string
+	Patterns []Pattern
+}
+
+// Teams maps contributors to teams. The first team with a pattern matching the name or the
+// email wins, in the order the teams are listed in the file.
+type Teams []Team
+
+// LoadTeams reads a YAML file mapping team names to lists of names, emails and patterns:
+//
+//	backend:
+//	  - Alice Johnson
+//	  - bob@example.com
+//	docs:
+//	  - "/@docs\\.example\\.com$/"
+func LoadTeams(path string) (Teams, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read teams file: %v", err)
+	}
+	var raw yaml.MapSlice
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse teams file %s: %v", path, err)
+	}
+	return ParseTeams(raw)
+}
+
+// ParseTeams builds teams from a mapping in the format of LoadTeams.
+func ParseTeams(raw yaml.MapSlice) (Teams, error) {
+	teams := make(Teams, 0, len(raw))
+	for _, item := range raw {
+		name := fmt.Sprint(item.Key)
+		members, ok := item.Value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("team %q must be a list of names, emails or patterns", name)
+		}
+		patterns := make([]string, 0, len(members))
+		for _, m := range members {
+			patterns = append(patterns, fmt.Sprint(m))
+		}
+		team, err := NewTeam(name, patterns)
+		if err != nil {
+			return nil, err
+		}
+		teams = append(teams, team)
+	}
+	return teams, nil
+}
+
+// NewTeam builds a team from the names, emails and patterns of its members.
+func NewTeam(name string, members []string) (Team, error) {
+	team := Team{Name: name}
+	for _, m := range members {
+		pattern, err := ParsePattern(m)
+		if err != nil {
+			return Team{}, fmt.Errorf("team %q: %v", name, err)
+		}
+		team.Patterns = append(team.Patterns, pattern)
+	}
+	return team, nil
+}
+
+// Team returns the team of the contributor with the given name and email.
+func (t Teams) Team(name, email string) string {
+	for _, team := range t {
+		if matchesAny(team.Patterns, name, email) {
+			return team.Name
+		}
+	}
+	return UnassignedTeam
+}
@@ -0,0 +1,101 @@
+package repository
+
+import "strings"
+
+// SplitList splits a comma-separated list of patterns, leaving commas inside braces
+// alone so that "*.{go,mod},docs/*" is the two patterns "*.{go,mod}" and "docs/*".
+// Empty items are dropped.
+func SplitList(s string) []string {
+	var items []string
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			if depth > 0 {
+				depth--
+			}
+		case ',':
+			if depth == 0 {
+				items = appendNonEmpty(items, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return appendNonEmpty(items, s[start:])
+}
+
+func appendNonEmpty(items []string, item string) []string {
+	if item = strings.TrimSpace(item); item != "" {
+		items = append(items, item)
+	}
+	return items
+}
+
+// ExpandBraces expands the brace alternatives of a pattern the way a shell does:
+// "src/*.{go,mod}" becomes "src/*.go" and "src/*.mod". Braces nest, and a pattern
+// without a complete brace group is returned as is.
+func ExpandBraces(pattern string) []string {
+	open, end, alternatives := findBraceGroup(pattern)
+	if open < 0 {
+		return []string{pattern}
+	}
+	var expanded []string
+	for _, alternative := range alternatives {
+		expanded = append(expanded, ExpandBraces(pattern[:open]+alternative+pattern[end+1:])...)
+	}
+	return expanded
+}
+
+// findBraceGroup returns the positions of the first top-level brace group of pattern
+// holding at least one comma, and its comma-separated alternatives.
+func findBraceGroup(pattern string) (int, int, []string) {
+	open, depth := -1, 0
+	var commas []int
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+		case '{':
+			if depth == 0 {
+				open, commas = i, nil
+			}
+			depth++
+		case '}':
+			if depth == 0 {
+				continue
+			}
+			depth--
+			if depth > 0 {
+				continue
+			}
+			if len(commas) == 0 {
+				open = -1
+				continue
+			}
+			var alternatives []string
+			start := open + 1
+			for _, c := range commas {
+				alternatives = append(alternatives, pattern[start:c])
+				start = c + 1
+			}
+			return open, i, append(alternatives, pattern[start:i])
+		case ',':
+			if depth == 1 {
+				commas = append(commas, i)
+			}
+		}
+	}
+	return -1, -1, nil
+}
+
+func expandAll(patterns []string) []string {
+	var expanded []string
+	for _, p := range patterns {
+		expanded = append(expanded, ExpandBraces(p)...)
+	}
+	return expanded
+}
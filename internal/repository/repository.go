@@ -6,7 +6,9 @@ import (
 	"os/exec"
 	"path/filepath"
 	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/GlebMoskalev/gitfame/configs"
 )
@@ -15,7 +17,13 @@ type Snapshot struct {
 	GitRootDir string
 	Files      []string
 	Revision   string
-	Filters    Filters
+	// Boundary is the start of an A..B revision range; only lines introduced after it are attributed.
+	Boundary   string
+	Commit     string
+	CommitTime time.Time
+	// At is the --at snapshot time the revision was resolved from, zero if not requested.
+	At      time.Time
+	Filters Filters
 }
 type Filters struct {
 	ExcludePatterns  []string
@@ -26,21 +34,33 @@ type Filters struct {
 func NewRepositorySnapshot(
 	repositoryPath,
 	revision,
-	extensionsArg,
-	excludeArg,
-	restrictArg,
-	languagesArg string) (*Snapshot, error) {
+	atArg string,
+	extensions,
+	exclude,
+	restrictTo,
+	languages []string) (*Snapshot, error) {
 	rs := &Snapshot{
-		Revision: revision,
-		Filters:  createFilters(extensionsArg, excludeArg, restrictArg, languagesArg),
+		Filters: createFilters(extensions, exclude, restrictTo, languages),
+	}
+	if err := rs.parseRevisionRange(revision); err != nil {
+		return nil, err
 	}
 	if err := rs.getGitRootDir(repositoryPath); err != nil {
 		return nil, err
 	}
 
+	// The revision is validated before --at resolves it, and the resolved commit again.
 	if err := rs.validateRevision(); err != nil {
 		return nil, err
 	}
+	if atArg != "" {
+		if err := rs.resolveAt(atArg); err != nil {
+			return nil, err
+		}
+		if err := rs.validateRevision(); err != nil {
+			return nil, err
+		}
+	}
 
 	if err := rs.getFilesFromGitTree(); err != nil {
 		return nil, err
@@ -50,16 +70,17 @@ func NewRepositorySnapshot(
 
 }
 
-func createFilters(extensionsArg, excludeArg, restrictArg, languagesArg string) Filters {
+// createFilters expands the brace alternatives of the patterns and extensions and adds
+// the extensions of the languages.
+func createFilters(extensions, exclude, restrictTo, languages []string) Filters {
 	filters := Filters{
-		ExcludePatterns:  splitIfNotEmpty(excludeArg),
-		RestrictPatterns: splitIfNotEmpty(restrictArg),
-		Extensions:       splitIfNotEmpty(extensionsArg),
+		ExcludePatterns:  expandAll(exclude),
+		RestrictPatterns: expandAll(restrictTo),
+		Extensions:       expandAll(extensions),
 	}
-	if languagesArg != "" {
+	if len(languages) > 0 {
 		languagesMap, err := configs.LoadLanguageExtensions()
 		if err == nil {
-			languages := strings.Split(languagesArg, ",")
 			for _, l := range languages {
 				languageExtensions, ok := languagesMap[l]
 				if !ok {
@@ -72,13 +93,6 @@ func createFilters(extensionsArg, excludeArg, restrictArg, languagesArg string)
 	return filters
 }
 
-func splitIfNotEmpty(s string) []string {
-	if s == "" {
-		return []string{}
-	}
-	return strings.Split(s, ",")
-}
-
 func (rs *Snapshot) getGitRootDir(path string) error {
 	if _, err := os.Stat(path); err != nil {
 		return fmt.Errorf("directory does not exist: %s", path)
@@ -98,62 +112,135 @@ func (rs *Snapshot) getGitRootDir(path string) error {
 
 }
 
+// RevisionRange returns the revision argument for git blame and git log: "A..B" when a
+// boundary is set and the plain revision otherwise.
+func (rs *Snapshot) RevisionRange() string {
+	if rs.Boundary == "" {
+		return rs.Revision
+	}
+	return rs.Boundary + ".." + rs.Revision
+}
+
+func (rs *Snapshot) parseRevisionRange(revision string) error {
+	if strings.Contains(revision, "...") {
+		return fmt.Errorf("symmetric difference is not supported: %s", revision)
+	}
+	boundary, end, isRange := strings.Cut(revision, "..")
+	// Revisions are passed to git commands, which would take a leading dash for an option.
+	if strings.HasPrefix(boundary, "-") || strings.HasPrefix(end, "-") {
+		return fmt.Errorf("invalid revision: %s", revision)
+	}
+	if !isRange {
+		rs.Revision = revision
+		return nil
+	}
+	if boundary == "" {
+		boundary = "HEAD"
+	}
+	if end == "" {
+		end = "HEAD"
+	}
+	rs.Boundary = boundary
+	rs.Revision = end
+	return nil
+}
+
+// resolveAt replaces the revision with the last first-parent commit committed before the
+// given date (YYYY-MM-DD, taken as midnight UTC) or RFC 3339 timestamp.
+func (rs *Snapshot) resolveAt(atArg string) error {
+	at, err := time.Parse(time.DateOnly, atArg)
+	if err != nil {
+		at, err = time.Parse(time.RFC3339, atArg)
+		if err != nil {
+			return fmt.Errorf("invalid at: %s, must be YYYY-MM-DD or RFC 3339 timestamp", atArg)
+		}
+	}
+
+	cmd := exec.Command("git", "rev-list", "-1", "--first-parent",
+		"--before="+at.UTC().Format(time.RFC3339), "--end-of-options", rs.Revision, "--")
+	cmd.Dir = rs.GitRootDir
+	out, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("failed to resolve revision %s at %s: %v", rs.Revision, atArg, err)
+	}
+	commit := strings.TrimSpace(string(out))
+	if commit == "" {
+		return fmt.Errorf("no commit on %s before %s", rs.Revision, atArg)
+	}
+	rs.Revision = commit
+	rs.At = at.UTC()
+	return nil
+}
+
 func (rs *Snapshot) validateRevision() error {
-	cmd := exec.Command("git", "cat-file", "commit", rs.Revision)
+	out, err := catCommit(rs.GitRootDir, rs.Revision)
+	if err != nil {
+		return err
+	}
+	rs.CommitTime = parseCommitterTime(out)
+
+	cmd := exec.Command("git", "rev-parse", "--verify", "--end-of-options", rs.Revision+"^{commit}")
 	cmd.Dir = rs.GitRootDir
-	if err := cmd.Run(); err != nil {
-		if exitErr := err.(*exec.ExitError); exitErr.ExitCode() == 128 {
-			return fmt.Errorf("invalid revision: %s", rs.Revision)
+	hash, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("failed to resolve revision: %v", err)
+	}
+	rs.Commit = strings.TrimSpace(string(hash))
+
+	if rs.Boundary != "" {
+		if _, err := catCommit(rs.GitRootDir, rs.Boundary); err != nil {
+			return err
 		}
-		return fmt.Errorf("failed to validate revision: %v", err)
 	}
 
 	return nil
 
 }
 
-func (rs *Snapshot) getFilesFromGitTree() error {
-	cmd := exec.Command("git", "ls-tree", "-r", rs.Revision)
-	cmd.Dir = rs.GitRootDir
+func catCommit(gitRootDir, revision string) (string, error) {
+	cmd := exec.Command("git", "cat-file", "commit", revision)
+	cmd.Dir = gitRootDir
 	out, err := cmd.Output()
 	if err != nil {
-		return err
+		if exitErr := err.(*exec.ExitError); exitErr.ExitCode() == 128 {
+			return "", fmt.Errorf("invalid revision: %s", revision)
+		}
+		return "", fmt.Errorf("failed to validate revision: %v", err)
 	}
+	return string(out), nil
+}
 
-	var files []string
-	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
-	for _, line := range lines {
-		fields := strings.Fields(line)
-		if len(fields) < 4 {
+func parseCommitterTime(commitObject string) time.Time {
+	for _, line := range strings.Split(commitObject, "\n") {
+		if line == "" {
+			break
+		}
+		if !strings.HasPrefix(line, "committer ") {
 			continue
 		}
-		if fields[1] == "blob" {
-			file := strings.Join(fields[3:], " ")
-			addFile := false
-			if len(rs.Filters.RestrictPatterns) != 0 {
-				if matchesAnyPattern(file, rs.Filters.RestrictPatterns) {
-					addFile = true
-				}
-			} else {
-				addFile = true
-			}
-			if len(rs.Filters.ExcludePatterns) != 0 {
-				if matchesAnyPattern(file, rs.Filters.ExcludePatterns) {
-					addFile = false
-				}
-			}
-
-			if addFile && len(rs.Filters.Extensions) != 0 {
-				if slices.Contains(rs.Filters.Extensions, filepath.Ext(file)) {
-					files = append(files, file)
-				}
-			} else if addFile {
-				files = append(files, file)
-			}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			break
 		}
+		seconds, err := strconv.ParseInt(fields[len(fields)-2], 10, 64)
+		if err != nil {
+			break
+		}
+		return time.Unix(seconds, 0).UTC()
+	}
+	return time.Time{}
+}
 
+func (rs *Snapshot) getFilesFromGitTree() error {
+	cmd := exec.Command("git", "ls-tree", "-r", rs.Revision)
+	cmd.Dir = rs.GitRootDir
+	out, err := cmd.Output()
+	if err != nil {
+		return err
 	}
-	rs.Files = files
+
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	rs.Files = rs.filterRepoFiles(lines)
 	return nil
 
 }
@@ -166,26 +253,25 @@ func (rs *Snapshot) filterRepoFiles(lines []string) []string {
 			continue
 		}
 		file := strings.Join(fields[3:], " ")
-		if rs.shouldIncludeFile(file) {
+		if rs.Filters.Match(file) {
 			files = append(files, file)
 		}
 	}
 	return files
 }
 
-func (rs *Snapshot) shouldIncludeFile(file string) bool {
-	if len(rs.Filters.RestrictPatterns) > 0 {
-		if !matchesAnyPattern(file, rs.Filters.ExcludePatterns) {
-			return false
-		}
+// Match reports whether file passes the restrict, exclude and extension filters.
+func (f Filters) Match(file string) bool {
+	if len(f.RestrictPatterns) > 0 && !matchesAnyPattern(file, f.RestrictPatterns) {
+		return false
 	}
 
-	if len(rs.Filters.ExcludePatterns) > 0 && matchesAnyPattern(file, rs.Filters.ExcludePatterns) {
+	if len(f.ExcludePatterns) > 0 && matchesAnyPattern(file, f.ExcludePatterns) {
 		return false
 	}
 
-	if len(rs.Filters.Extensions) > 0 {
-		return slices.Contains(rs.Filters.Extensions, filepath.Ext(file))
+	if len(f.Extensions) > 0 {
+		return slices.Contains(f.Extensions, filepath.Ext(file))
 	}
 	return true
 
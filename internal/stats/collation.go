@@ -0,0 +1,35 @@
+package stats
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+// DefaultCollation is the Unicode root collation, which orders names of most scripts
+// sensibly without preferring the conventions of a language.
+const DefaultCollation = "und"
+
+// ParseCollation returns a case-insensitive collator for a BCP 47 language tag such as
+// "en", "de" or "sv".
+func ParseCollation(tag string) (*collate.Collator, error) {
+	if tag == "" {
+		tag = DefaultCollation
+	}
+	t, err := language.Parse(tag)
+	if err != nil {
+		return nil, fmt.Errorf("invalid collation: '%s', must be a BCP 47 language tag such as en or de", tag)
+	}
+	return collate.New(t, collate.IgnoreCase), nil
+}
+
+// compareText orders strings by the collator, falling back to code points for strings
+// it considers equal so that the order is deterministic.
+func compareText(collator *collate.Collator, a, b string) int {
+	if c := collator.CompareString(a, b); c != 0 {
+		return c
+	}
+	return strings.Compare(a, b)
+}
@@ -0,0 +1,118 @@
+package stats
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GlebMoskalev/gitfame/internal/blame"
+	"github.com/GlebMoskalev/gitfame/pkg/report"
+)
+
+var (
+	nameColumn             = report.Column{Header: "Name", Text: true, Value: func(c *report.Contributor) string { return c.Name }}
+	linesColumn            = report.Column{Header: "Lines", Value: func(c *report.Contributor) string { return strconv.Itoa(c.Lines) }}
+	commitsColumn          = report.Column{Header: "Commits", Value: func(c *report.Contributor) string { return strconv.Itoa(c.Commits) }}
+	survivingCommitsColumn = report.Column{
+		Header: "Surviving commits",
+		Value:  func(c *report.Contributor) string { return strconv.Itoa(c.SurvivingCommits) },
+	}
+	filesColumn = report.Column{Header: "Files", Value: func(c *report.Contributor) string { return strconv.Itoa(c.Files) }}
+)
+
+func tableColumns(opts Options) []report.Column {
+	columns := []report.Column{nameColumn}
+	switch {
+	case opts.Churn:
+		columns = append(columns, churnColumns()...)
+		columns = append(columns, commitsColumn, filesColumn)
+	case opts.LineAge:
+		columns = append(columns, linesColumn)
+		columns = append(columns, ageColumns()...)
+	default:
+		columns = append(columns, linesColumn, commitsColumn)
+		if opts.CommitSource == blame.CommitSourceLog {
+			columns = append(columns, survivingCommitsColumn)
+		}
+		columns = append(columns, filesColumn)
+	}
+	if opts.ShowShare {
+		columns = append(columns, shareColumns()...)
+	}
+	if opts.ShowEmail {
+		columns = append(columns, report.Column{
+			Header: "Emails",
+			Text:   true,
+			Value:  func(c *report.Contributor) string { return strings.Join(c.Emails, ", ") },
+		})
+	}
+	if opts.ShowActivity {
+		columns = append(columns,
+			report.Column{Header: "First", Text: true, Value: func(c *report.Contributor) string {
+				return formatDate(c.FirstContribution)
+			}},
+			report.Column{Header: "Last", Text: true, Value: func(c *report.Contributor) string {
+				return formatDate(c.LastContribution)
+			}},
+		)
+	}
+	return columns
+}
+
+func shareColumns() []report.Column {
+	share := func(header string, get func(*report.Share) float64) report.Column {
+		return report.Column{Header: header, Value: func(c *report.Contributor) string {
+			// Only the totals row has no share.
+			if c.Share == nil {
+				return "100.0"
+			}
+			return strconv.FormatFloat(get(c.Share), 'f', 1, 64)
+		}}
+	}
+	return []report.Column{
+		share("Lines %", func(s *report.Share) float64 { return s.Lines }),
+		share("Commits %", func(s *report.Share) float64 { return s.Commits }),
+		share("Files %", func(s *report.Share) float64 { return s.Files }),
+	}
+}
+
+func churnColumns() []report.Column {
+	churn := func(header string, get func(*report.LineChurn) int) report.Column {
+		return report.Column{Header: header, Value: func(c *report.Contributor) string {
+			if c.Churn == nil {
+				return "0"
+			}
+			return strconv.Itoa(get(c.Churn))
+		}}
+	}
+	return []report.Column{
+		churn("Added", func(l *report.LineChurn) int { return l.Added }),
+		churn("Deleted", func(l *report.LineChurn) int { return l.Deleted }),
+	}
+}
+
+func ageColumns() []report.Column {
+	bucket := func(header string, get func(*report.LineAge) int) report.Column {
+		return report.Column{Header: header, Value: func(c *report.Contributor) string {
+			if c.Age == nil {
+				return "0"
+			}
+			return strconv.Itoa(get(c.Age))
+		}}
+	}
+	return []report.Column{
+		bucket("<1m", func(a *report.LineAge) int { return a.UnderMonth }),
+		bucket("<6m", func(a *report.LineAge) int { return a.UnderHalfYear }),
+		bucket("<1y", func(a *report.LineAge) int { return a.UnderYear }),
+		bucket("<3y", func(a *report.LineAge) int { return a.UnderThreeYears }),
+		bucket("Older", func(a *report.LineAge) int { return a.Older }),
+		bucket("Median days", func(a *report.LineAge) int { return a.MedianDays }),
+	}
+}
+
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format(time.DateOnly)
+}
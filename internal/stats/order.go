@@ -0,0 +1,145 @@
+package stats
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"golang.org/x/text/collate"
+
+	"github.com/GlebMoskalev/gitfame/internal/blame"
+	"github.com/GlebMoskalev/gitfame/pkg/report"
+)
+
+type sortField string
+
+const (
+	sortByLines       sortField = "lines"
+	sortByCommits     sortField = "commits"
+	sortByFiles       sortField = "files"
+	sortByName        sortField = "name"
+	sortByEmail       sortField = "email"
+	sortByShare       sortField = "share"
+	sortByFirstActive sortField = "first-active"
+	sortByLastActive  sortField = "last-active"
+	sortByAdded       sortField = "added"
+	sortByDeleted     sortField = "deleted"
+)
+
+const (
+	ascending  = "asc"
+	descending = "desc"
+)
+
+// sortFields compare two contributors in ascending order.
+var sortFields = map[sortField]func(a, b *blame.ContributorStats) int{
+	sortByLines:   func(a, b *blame.ContributorStats) int { return cmp.Compare(a.Lines, b.Lines) },
+	sortByCommits: func(a, b *blame.ContributorStats) int { return cmp.Compare(a.Commits, b.Commits) },
+	sortByFiles:   func(a, b *blame.ContributorStats) int { return cmp.Compare(a.Files, b.Files) },
+	// The share of lines orders contributors like their lines; it is computed only after sorting.
+	sortByShare:       func(a, b *blame.ContributorStats) int { return cmp.Compare(a.Lines, b.Lines) },
+	sortByFirstActive: func(a, b *blame.ContributorStats) int { return a.FirstContribution.Compare(b.FirstContribution) },
+	sortByLastActive:  func(a, b *blame.ContributorStats) int { return a.LastContribution.Compare(b.LastContribution) },
+	sortByAdded:       func(a, b *blame.ContributorStats) int { return cmp.Compare(churn(a).Added, churn(b).Added) },
+	sortByDeleted:     func(a, b *blame.ContributorStats) int { return cmp.Compare(churn(a).Deleted, churn(b).Deleted) },
+}
+
+// textSortFields are compared with the collator of --collation.
+var textSortFields = map[sortField]func(c *blame.ContributorStats) string{
+	sortByName:  func(c *blame.ContributorStats) string { return c.Name },
+	sortByEmail: firstEmail,
+}
+
+// SortFields lists the keys accepted by --order-by.
+var SortFields = []string{
+	string(sortByLines), string(sortByCommits), string(sortByFiles), string(sortByName), string(sortByEmail),
+	string(sortByShare), string(sortByFirstActive), string(sortByLastActive), string(sortByAdded), string(sortByDeleted),
+}
+
+// SortKey is a field of an --order-by list with its direction.
+type SortKey struct {
+	Field      string
+	Descending bool
+}
+
+// tieBreakers settle contributors equal on all requested keys: most lines, commits
+// and files first, then by name.
+var tieBreakers = []SortKey{
+	{Field: string(sortByLines), Descending: true},
+	{Field: string(sortByCommits), Descending: true},
+	{Field: string(sortByFiles), Descending: true},
+	{Field: string(sortByName)},
+}
+
+// ParseOrderBy parses a comma-separated --order-by list such as "files:desc,name:asc".
+// Without a direction names and emails sort ascending and every other field descending.
+func ParseOrderBy(orderBy string) ([]SortKey, error) {
+	var keys []SortKey
+	for _, item := range strings.Split(orderBy, ",") {
+		field, direction, hasDirection := strings.Cut(strings.TrimSpace(item), ":")
+		_, numeric := sortFields[sortField(field)]
+		if _, text := textSortFields[sortField(field)]; !numeric && !text {
+			return nil, fmt.Errorf("invalid order-by field: '%s', must be one of: %s",
+				field, strings.Join(SortFields, ", "))
+		}
+		key := SortKey{Field: field, Descending: numeric}
+		if hasDirection {
+			switch direction {
+			case ascending:
+				key.Descending = false
+			case descending:
+				key.Descending = true
+			default:
+				return nil, fmt.Errorf("invalid order-by direction: '%s', must be one of: asc, desc", direction)
+			}
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func sortContributors(contributors []*blame.ContributorStats, orderBy string, collator *collate.Collator) error {
+	keys, err := ParseOrderBy(orderBy)
+	if err != nil {
+		return err
+	}
+	for _, tieBreaker := range tieBreakers {
+		if !slices.ContainsFunc(keys, func(k SortKey) bool { return k.Field == tieBreaker.Field }) {
+			keys = append(keys, tieBreaker)
+		}
+	}
+
+	slices.SortStableFunc(contributors, func(a, b *blame.ContributorStats) int {
+		for _, key := range keys {
+			var c int
+			if text, ok := textSortFields[sortField(key.Field)]; ok {
+				c = compareText(collator, text(a), text(b))
+			} else {
+				c = sortFields[sortField(key.Field)](a, b)
+			}
+			if key.Descending {
+				c = -c
+			}
+			if c != 0 {
+				return c
+			}
+		}
+		return 0
+	})
+	return nil
+}
+
+func firstEmail(c *blame.ContributorStats) string {
+	if len(c.Emails) == 0 {
+		return ""
+	}
+	return c.Emails[0]
+}
+
+func churn(c *blame.ContributorStats) report.LineChurn {
+	if c.Churn == nil {
+		return report.LineChurn{}
+	}
+	return *c.Churn
+}
@@ -1,173 +1,325 @@
 package stats
 
 import (
-	"encoding/csv"
-	"encoding/json"
 	"fmt"
 	"github.com/GlebMoskalev/gitfame/pkg/progressbar"
 	"io"
 	"os"
-	"sort"
-	"strconv"
-	"strings"
-	"text/tabwriter"
+	"text/template"
+	"time"
 
 	"github.com/GlebMoskalev/gitfame/internal/blame"
+	"github.com/GlebMoskalev/gitfame/internal/identity"
 	"github.com/GlebMoskalev/gitfame/internal/repository"
+	"github.com/GlebMoskalev/gitfame/pkg/report"
 )
 
-type outputFormat string
+type Options struct {
+	RepositoryPath string
+	Revision       string
+	At             string
+	Extensions     []string
+	Exclude        []string
+	RestrictTo     []string
+	Languages      []string
+	OrderBy        string
+	// Collation is the BCP 47 language tag names are sorted by, DefaultCollation if empty.
+	Collation      string
+	NormalizeNames bool
+	Format         string
+	UseCommitter   bool
+	ShowProgress   bool
+	ShowEmail      bool
+	ShowActivity   bool
+	LineAge        bool
+	CommitSource   string
+	LogFiltered    bool
+	Churn          bool
+	Since          string
+	Until          string
+	ShowMetadata   bool
+	ExcludeAuthors string
+	OnlyAuthors    string
+	OthersBucket   bool
+	TeamsFile      string
+	// Teams are used with GroupByTeam when TeamsFile is empty, in the order of a teams file.
+	Teams      []Team
+	GroupBy    string
+	ShowShare  bool
+	ShowTotals bool
+	Top        int
+	MinLines   int
+	MinShare   float64
+	// Version is reported as gitfame_version in the JSON metadata.
+	Version string
+	// Template is the text of the template used by the template format.
+	Template string
+	// Outputs are files the results are written to instead of Out, each in the format
+	// registered for its extension, or in Format if there is none.
+	Outputs []string
+	// Progress receives the progress of the run instead of the progress bar of ShowProgress.
+	Progress progressbar.Progress
+	// Out receives the formatted results, os.Stdout if nil.
+	Out io.Writer
+}
 
 const (
-	formatTabular   outputFormat = "tabular"
-	formatCSV       outputFormat = "csv"
-	formatJSON      outputFormat = "json"
-	formatJSONLines outputFormat = "json-lines"
+	GroupByAuthor = "author"
+	GroupByTeam   = "team"
 )
 
-type sortField string
+// CalculateStats analyzes the repository and writes the results in the requested format.
+func CalculateStats(opts Options) (err error) {
+	start := time.Now()
+	progress := opts.Progress
+	if progress == nil && opts.ShowProgress && progressbar.IsTerminal(os.Stderr) {
+		// Churn reads the history in a single pass instead of blaming and aggregating.
+		phases := 3
+		if opts.Churn {
+			phases = 2
+		}
+		progress, _ = progressbar.New(phases, os.Stderr)
+	}
+	closeProgress := func(err error) {
+		if progress == nil {
+			return
+		}
+		if failer, ok := progress.(progressbar.Failer); ok && err != nil {
+			failer.Fail(err)
+		} else {
+			progress.Close()
+		}
+		progress = nil
+	}
+	defer func() { closeProgress(err) }()
+	if progress != nil {
+		progress.StartPhase("listing files", 0)
+	}
 
-const (
-	sortByLines   sortField = "lines"
-	sortByCommits sortField = "commits"
-	sortByFiles   sortField = "files"
-)
+	var tmpl *template.Template
+	if opts.Template != "" {
+		var err error
+		tmpl, err = report.ParseTemplate("template", opts.Template)
+		if err != nil {
+			return fmt.Errorf("failed to parse template: %v", err)
+		}
+	}
+	collator, err := ParseCollation(opts.Collation)
+	if err != nil {
+		return err
+	}
 
-func CalculateStats(
-	repositoryPath, revision, extensionsArg, excludeArg, restrictArg, languagesArg, orderBy, format string,
-	useCommitter, useProgress bool) {
-	var bar *progressbar.ProgressBar
-	if useProgress {
-		bar, _ = progressbar.New(2, os.Stdout)
+	rs, err := repository.NewRepositorySnapshot(
+		opts.RepositoryPath, opts.Revision, opts.At, opts.Extensions, opts.Exclude, opts.RestrictTo, opts.Languages)
+	if err != nil {
+		return fmt.Errorf("failed to create repository snapshot: %v", err)
 	}
 
-	rs, err := repository.NewRepositorySnapshot(repositoryPath, revision, extensionsArg, excludeArg, restrictArg, languagesArg)
+	blameOptions := blame.Options{
+		UseCommitter:     opts.UseCommitter,
+		LineAge:          opts.LineAge,
+		CommitSource:     opts.CommitSource,
+		LogFilteredPaths: opts.LogFiltered,
+		Since:            opts.Since,
+		Until:            opts.Until,
+		NormalizeNames:   opts.NormalizeNames,
+	}
+	blameOptions.Identity, err = identityFunc(opts)
 	if err != nil {
-		exitWithError("Failed to create repository snapshot", err)
+		return fmt.Errorf("failed to load contributor identities: %v", err)
+	}
+	var contributors []*blame.ContributorStats
+	skipped := make([]string, 0)
+	if opts.Churn {
+		if progress != nil {
+			progress.StartPhase("reading history", 0)
+		}
+		contributors, err = blame.GetChurnStats(rs, blameOptions)
+		if err != nil {
+			return fmt.Errorf("failed to calculate churn: %v", err)
+		}
+	} else {
+		contributors, skipped = blame.GetContributorStats(rs, blameOptions, progress)
 	}
 
-	contributors := blame.GetContributorStats(rs, useCommitter, bar)
+	if err := sortContributors(contributors, opts.OrderBy, collator); err != nil {
+		return err
+	}
 
-	sortContributors(contributors, sortField(orderBy))
+	// Clears the bar before results reach a terminal shared with stderr.
+	closeProgress(nil)
 
-	err = outputResults(contributors, outputFormat(format), os.Stdout)
-	if err != nil {
-		exitWithError("Failed to output results", err)
+	total := blame.Merge("Total", contributors)
+	contributorCount := countContributors(contributors)
+	contributors = truncateContributors(contributors, opts, total)
+	if opts.ShowShare {
+		blame.AddShares(contributors, total)
 	}
 
-}
+	// The author patterns were validated when building the identity function.
+	excludeAuthors, _ := identity.SplitPatterns(opts.ExcludeAuthors)
+	onlyAuthors, _ := identity.SplitPatterns(opts.OnlyAuthors)
+	r := &report.Report{
+		Contributors: make([]*report.Contributor, 0, len(contributors)),
+		Columns:      tableColumns(opts),
+		ShowMetadata: opts.ShowMetadata,
+		Template:     tmpl,
+		Metadata: &report.Metadata{
+			SchemaVersion: report.SchemaVersion,
+			Version:       opts.Version,
+			Repository:    rs.GitRootDir,
+			Revision:      opts.Revision,
+			Commit:        rs.Commit,
+			At:            rs.At,
+			Filters: report.Filters{
+				Extensions:     nonNil(opts.Extensions),
+				Languages:      nonNil(opts.Languages),
+				Exclude:        nonNil(opts.Exclude),
+				RestrictTo:     nonNil(opts.RestrictTo),
+				ExcludeAuthors: nonNil(excludeAuthors),
+				OnlyAuthors:    nonNil(onlyAuthors),
+			},
+			Files:           len(rs.Files),
+			SkippedFiles:    skipped,
+			DurationSeconds: time.Since(start).Round(time.Millisecond).Seconds(),
+			GeneratedAt:     time.Now().UTC().Truncate(time.Second),
+			Totals:          report.NewTotals(&total.Contributor, contributorCount),
+		},
+	}
+	for _, c := range contributors {
+		r.Contributors = append(r.Contributors, &c.Contributor)
+	}
+	if opts.ShowTotals {
+		r.Total = &total.Contributor
+	}
 
-func exitWithError(message string, err error) {
-	fmt.Printf("%s: %v\n", message, err)
-	os.Exit(1)
-}
+	for _, path := range opts.Outputs {
+		if err := report.WriteFile(r, OutputFormat(path, opts.Format), path); err != nil {
+			return fmt.Errorf("failed to write %s: %v", path, err)
+		}
+	}
+	if len(opts.Outputs) > 0 {
+		return nil
+	}
 
-func sortContributors(contributors []*blame.ContributorStats, orderBy sortField) {
-	sorters := map[sortField]func(i, j int) bool{
-		sortByLines: func(i, j int) bool {
-			if contributors[i].Lines != contributors[j].Lines {
-				return contributors[i].Lines > contributors[j].Lines
-			}
-			if contributors[i].Commits != contributors[j].Commits {
-				return contributors[i].Commits > contributors[j].Commits
-			}
-			if contributors[i].Files != contributors[j].Files {
-				return contributors[i].Files > contributors[j].Files
-			}
-			return strings.ToLower(contributors[i].Name) < strings.ToLower(contributors[j].Name)
-		},
-		sortByCommits: func(i, j int) bool {
-			if contributors[i].Commits != contributors[j].Commits {
-				return contributors[i].Commits > contributors[j].Commits
-			}
-			if contributors[i].Lines != contributors[j].Lines {
-				return contributors[i].Lines > contributors[j].Lines
-			}
-			if contributors[i].Files != contributors[j].Files {
-				return contributors[i].Files > contributors[j].Files
-			}
-			return strings.ToLower(contributors[i].Name) < strings.ToLower(contributors[j].Name)
-		},
-		sortByFiles: func(i, j int) bool {
-			if contributors[i].Files != contributors[j].Files {
-				return contributors[i].Files > contributors[j].Files
-			}
-			if contributors[i].Lines != contributors[j].Lines {
-				return contributors[i].Lines > contributors[j].Lines
-			}
-			if contributors[i].Commits != contributors[j].Commits {
-				return contributors[i].Commits > contributors[j].Commits
-			}
-			return strings.ToLower(contributors[i].Name) < strings.ToLower(contributors[j].Name)
-		},
+	out := opts.Out
+	if out == nil {
+		out = os.Stdout
 	}
-	sortFunc, ok := sorters[orderBy]
-	if !ok {
-		exitWithError("Invalid sort field", fmt.Errorf("unknown sort field: %s", orderBy))
+	if err := report.Write(r, opts.Format, out); err != nil {
+		return fmt.Errorf("failed to output results: %v", err)
 	}
-	sort.Slice(contributors, sortFunc)
+	return nil
 }
 
-func outputResults(contributors []*blame.ContributorStats, format outputFormat, out io.Writer) error {
-	formatters := map[outputFormat]func([]*blame.ContributorStats, io.Writer) error{
-		formatTabular:   outputTabular,
-		formatCSV:       outputCSV,
-		formatJSON:      outputJSON,
-		formatJSONLines: outputJSONLines,
-	}
-	formatter, ok := formatters[format]
-	if !ok {
-		return fmt.Errorf("unsupported format: %s", format)
+// OutputFormat returns the format an --output file is written in.
+func OutputFormat(path, format string) string {
+	if f, ok := report.FormatForPath(path); ok {
+		return f
 	}
-	return formatter(contributors, out)
+	return format
 }
 
-func outputTabular(contributors []*blame.ContributorStats, out io.Writer) error {
-	w := tabwriter.NewWriter(out, 0, 0, 1, ' ', 0)
-	_, err := fmt.Fprintln(w, "Name\tLines\tCommits\tFiles")
+// identityFunc combines the author filter with the team mapping of --group-by=team.
+func identityFunc(opts Options) (blame.IdentityFunc, error) {
+	filter, err := authorFilter(opts)
 	if err != nil {
-		return fmt.Errorf("failed to output tabular: %v", err)
+		return nil, err
 	}
-	for _, e := range contributors {
-		_, err = fmt.Fprintf(w, "%s\t%d\t%d\t%d\n", e.Name, e.Lines, e.Commits, e.Files)
-		if err != nil {
-			return fmt.Errorf("failed to output tabular: %v", err)
+	if opts.GroupBy != GroupByTeam {
+		if filter == nil {
+			return nil, nil
 		}
+		return filter.Key, nil
 	}
-	err = w.Flush()
+
+	teams, err := buildTeams(opts)
 	if err != nil {
-		return fmt.Errorf("failed to output tabular: %v", err)
+		return nil, err
 	}
-	return nil
+	return func(name, email string) (string, bool) {
+		if filter != nil {
+			key, ok := filter.Key(name, email)
+			if !ok || key == identity.OthersName {
+				return key, ok
+			}
+		}
+		return teams.Team(name, email), true
+	}, nil
 }
 
-func outputCSV(contributors []*blame.ContributorStats, out io.Writer) error {
-	w := csv.NewWriter(out)
-	if err := w.Write([]string{"Name", "Lines", "Commits", "Files"}); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+func authorFilter(opts Options) (*identity.Filter, error) {
+	if opts.ExcludeAuthors == "" && opts.OnlyAuthors == "" {
+		return nil, nil
 	}
-	for _, e := range contributors {
-		if err := w.Write([]string{e.Name, strconv.Itoa(e.Lines), strconv.Itoa(e.Commits), strconv.Itoa(e.Files)}); err != nil {
-			fmt.Println(err)
-			os.Exit(1)
-		}
+	exclude, err := parseAuthorPatterns(opts.ExcludeAuthors)
+	if err != nil {
+		return nil, err
+	}
+	only, err := parseAuthorPatterns(opts.OnlyAuthors)
+	if err != nil {
+		return nil, err
 	}
-	w.Flush()
-	return w.Error()
+	return &identity.Filter{
+		Exclude:      exclude,
+		Only:         only,
+		OthersBucket: opts.OthersBucket,
+	}, nil
 }
 
-func outputJSON(contributors []*blame.ContributorStats, out io.Writer) error {
-	encoder := json.NewEncoder(out)
-	return encoder.Encode(contributors)
+func parseAuthorPatterns(s string) ([]identity.Pattern, error) {
+	patterns, err := identity.SplitPatterns(s)
+	if err != nil {
+		return nil, err
+	}
+	return identity.ParsePatterns(patterns)
 }
 
-func outputJSONLines(contributors []*blame.ContributorStats, out io.Writer) error {
-	encoder := json.NewEncoder(out)
+// nonNil returns an empty list for nil, so that unset filters are written as [] rather than null.
+func nonNil(list []string) []string {
+	if list == nil {
+		return []string{}
+	}
+	return list
+}
+
+// truncateContributors keeps the contributors within --top, --min-lines and --min-share
+// and collapses the rest into a single "others" row, so the totals are preserved.
+func truncateContributors(contributors []*blame.ContributorStats, opts Options,
+	total *blame.ContributorStats) []*blame.ContributorStats {
+	var kept, rest, bucket []*blame.ContributorStats
 	for _, c := range contributors {
-		if err := encoder.Encode(c); err != nil {
-			return err
+		// An "others" bucket from the author filter does not take a --top slot; it joins
+		// the collapsed row.
+		if c.Name == identity.OthersName {
+			bucket = append(bucket, c)
+			continue
+		}
+		share := 0.0
+		if total.Lines > 0 {
+			share = float64(c.Lines) * 100 / float64(total.Lines)
 		}
+		if (opts.Top > 0 && len(kept) >= opts.Top) || c.Lines < opts.MinLines || share < opts.MinShare {
+			rest = append(rest, c)
+			continue
+		}
+		kept = append(kept, c)
 	}
-	return nil
+	// The bucket stands for several people and goes last, whatever its lines.
+	if len(rest) == 0 {
+		return append(kept, bucket...)
+	}
+	return append(kept, blame.Merge(identity.OthersName, append(rest, bucket...)))
+}
+
+// countContributors returns the number of contributors, not counting the "others"
+// bucket of the author filter, which stands for several people.
+func countContributors(contributors []*blame.ContributorStats) int {
+	count := 0
+	for _, c := range contributors {
+		if c.Name != identity.OthersName {
+			count++
+		}
+	}
+	return count
 }
@@ -0,0 +1,24 @@
+package stats
+
+import "github.com/GlebMoskalev/gitfame/internal/identity"
+
+// Team lists the names, emails and patterns of the members of a team, as in a teams file.
+type Team struct {
+	Name    string
+	Members []string
+}
+
+func buildTeams(opts Options) (identity.Teams, error) {
+	if opts.TeamsFile != "" {
+		return identity.LoadTeams(opts.TeamsFile)
+	}
+	teams := make(identity.Teams, 0, len(opts.Teams))
+	for _, t := range opts.Teams {
+		team, err := identity.NewTeam(t.Name, t.Members)
+		if err != nil {
+			return nil, err
+		}
+		teams = append(teams, team)
+	}
+	return teams, nil
+}
@@ -0,0 +1,17 @@
+// Package gitfame exposes the gitfame analysis to other programs, so that
+// formats registered with the report package can be used outside the CLI.
+package gitfame
+
+import "github.com/GlebMoskalev/gitfame/internal/stats"
+
+// Options configures a run; it mirrors the command line flags.
+type Options = stats.Options
+
+// Team is an entry of Options.Teams: a team name with the names, emails and patterns of
+// its members.
+type Team = stats.Team
+
+// Run analyzes the repository and writes the report to opts.Out (os.Stdout if nil).
+func Run(opts Options) error {
+	return stats.CalculateStats(opts)
+}
@@ -0,0 +1,105 @@
+package progressbar
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// JSONProgress writes progress as newline-delimited JSON events, one object per line:
+//
+//	{"event":"phase","phase":"blaming","total":152}
+//	{"event":"file","file":"main.go","lines":120,"duration_ms":4.1}
+//	{"event":"error","file":"broken.go","error":"git blame failed ..."}
+//	{"event":"finished","duration_ms":812.5}
+//
+// A failed run ends with a finished event carrying the error of the run:
+//
+//	{"event":"finished","duration_ms":3.2,"error":"failed to create repository snapshot: ..."}
+//
+// It is safe for concurrent use.
+type JSONProgress struct {
+	encoder *json.Encoder
+	start   time.Time
+	closed  bool
+	mu      sync.Mutex
+}
+
+func NewJSON(out io.Writer) *JSONProgress {
+	return &JSONProgress{encoder: json.NewEncoder(out), start: time.Now()}
+}
+
+type phaseEvent struct {
+	Event string `json:"event"`
+	Phase string `json:"phase"`
+	Total int    `json:"total"`
+}
+
+type fileEvent struct {
+	Event      string  `json:"event"`
+	File       string  `json:"file"`
+	Lines      int     `json:"lines"`
+	DurationMS float64 `json:"duration_ms"`
+}
+
+type errorEvent struct {
+	Event string `json:"event"`
+	File  string `json:"file"`
+	Error string `json:"error"`
+}
+
+type finishedEvent struct {
+	Event      string  `json:"event"`
+	DurationMS float64 `json:"duration_ms"`
+	Error      string  `json:"error,omitempty"`
+}
+
+func (p *JSONProgress) StartPhase(name string, total int) {
+	p.emit(phaseEvent{Event: "phase", Phase: name, Total: total})
+}
+
+func (p *JSONProgress) FileDone(e FileEvent) {
+	if e.Err != nil {
+		p.emit(errorEvent{Event: "error", File: e.File, Error: e.Err.Error()})
+		return
+	}
+	p.emit(fileEvent{Event: "file", File: e.File, Lines: e.Lines, DurationMS: milliseconds(e.Duration)})
+}
+
+// Close emits the finished event; later events are dropped.
+func (p *JSONProgress) Close() {
+	p.finish(finishedEvent{Event: "finished"})
+}
+
+// Fail emits the finished event with the error of the run; later events are dropped.
+func (p *JSONProgress) Fail(err error) {
+	p.finish(finishedEvent{Event: "finished", Error: err.Error()})
+}
+
+func (p *JSONProgress) finish(event finishedEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.closed {
+		event.DurationMS = milliseconds(time.Since(p.start))
+		p.encode(event)
+		p.closed = true
+	}
+}
+
+func (p *JSONProgress) emit(event any) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.closed {
+		p.encode(event)
+	}
+}
+
+func (p *JSONProgress) encode(event any) {
+	// Progress is best effort: a closed stderr must not fail the run.
+	_ = p.encoder.Encode(event)
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Round(100*time.Microsecond)) / float64(time.Millisecond)
+}
@@ -0,0 +1,69 @@
+package progressbar
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONProgress(t *testing.T) {
+	var out bytes.Buffer
+	p := NewJSON(&out)
+
+	p.StartPhase("blaming", 2)
+	p.FileDone(FileEvent{File: "main.go", Lines: 10})
+	p.FileDone(FileEvent{File: "broken.go", Err: errors.New("git blame failed")})
+	p.Close()
+	p.Close()
+	p.StartPhase("aggregating", 0)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 4)
+	assert.JSONEq(t, `{"event":"phase","phase":"blaming","total":2}`, lines[0])
+	assert.JSONEq(t, `{"event":"file","file":"main.go","lines":10,"duration_ms":0}`, lines[1])
+	assert.JSONEq(t, `{"event":"error","file":"broken.go","error":"git blame failed"}`, lines[2])
+	assert.Contains(t, lines[3], `{"event":"finished","duration_ms":`)
+}
+
+func TestJSONProgressFail(t *testing.T) {
+	var out bytes.Buffer
+	p := NewJSON(&out)
+
+	p.Fail(errors.New("invalid revision"))
+	p.Close()
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 1)
+	var event map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &event))
+	assert.Equal(t, "finished", event["event"])
+	assert.Equal(t, "invalid revision", event["error"])
+}
+
+func TestJSONProgressConcurrentEvents(t *testing.T) {
+	var out bytes.Buffer
+	p := NewJSON(&out)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.FileDone(FileEvent{File: "main.go", Lines: 1})
+		}()
+	}
+	wg.Wait()
+	p.Close()
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 51)
+	for _, l := range lines {
+		assert.True(t, json.Valid([]byte(l)), l)
+	}
+}
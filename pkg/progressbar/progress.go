@@ -0,0 +1,65 @@
+package progressbar
+
+import "time"
+
+// Progress receives the progress of a gitfame run. Implementations must be safe for
+// concurrent use: FileDone is called from the goroutines blaming the files.
+type Progress interface {
+	// StartPhase is called when the run enters a phase of total files, 0 if unknown.
+	StartPhase(name string, total int)
+	// FileDone is called after a file of the current phase has been processed.
+	FileDone(e FileEvent)
+	// Close is called when the analysis is finished, before the results are written.
+	Close()
+}
+
+// Failer is implemented by a Progress that reports a failed run: Fail is called with the
+// error of the run instead of Close.
+type Failer interface {
+	Fail(err error)
+}
+
+// FileEvent describes a processed file.
+type FileEvent struct {
+	File     string
+	Lines    int
+	Duration time.Duration
+	// Err is set if the file could not be processed and is left out of the results.
+	Err error
+}
+
+// Callbacks adapts functions to the Progress interface; nil functions are skipped.
+// The functions are called concurrently and must synchronize themselves.
+type Callbacks struct {
+	OnPhase func(name string, total int)
+	OnFile  func(e FileEvent)
+	OnClose func()
+	// OnFail is called instead of OnClose if the run fails; OnClose is called if it is nil.
+	OnFail func(err error)
+}
+
+func (c Callbacks) StartPhase(name string, total int) {
+	if c.OnPhase != nil {
+		c.OnPhase(name, total)
+	}
+}
+
+func (c Callbacks) FileDone(e FileEvent) {
+	if c.OnFile != nil {
+		c.OnFile(e)
+	}
+}
+
+func (c Callbacks) Close() {
+	if c.OnClose != nil {
+		c.OnClose()
+	}
+}
+
+func (c Callbacks) Fail(err error) {
+	if c.OnFail != nil {
+		c.OnFail(err)
+		return
+	}
+	c.Close()
+}
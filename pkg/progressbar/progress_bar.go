@@ -3,41 +3,81 @@ package progressbar
 import (
 	"fmt"
 	"io"
+	"os"
 	"strings"
 	"sync"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+const (
+	// defaultWidth is used when the output is not a terminal or its size is unknown.
+	defaultWidth = 80
+	minFileWidth = 12
 )
 
+// ProgressBar renders the progress of a run made of consecutive phases on a single line:
+//
+//	[2/3] blaming |████████░░░░░░░░░░░░| 41.2% 110/267 files 36.1 files/s 2950 lines/s elapsed 3s ETA 4s internal/x.go
+//
+// Phases with an unknown total show only their name and the elapsed time.
+// All methods are safe for concurrent use.
 type ProgressBar struct {
-	progress, total, width int
+	phases, phase          int
+	phaseName              string
+	progress, total, lines int
+	current                string
+	phaseStart             time.Time
+	barWidth, width        int
 	closed                 bool
 	fieldCharacter         string
 	emptyCharacter         string
 	prefix                 string
 	suffix                 string
 	out                    io.Writer
+	now                    func() time.Time
 	err                    error
 	mu                     sync.Mutex
 }
 
-func New(t int, out io.Writer) (*ProgressBar, error) {
-	if t <= 0 {
-		return nil, fmt.Errorf("total must be greater than 0")
+// New returns a progress bar for a run of the given number of phases.
+func New(phases int, out io.Writer) (*ProgressBar, error) {
+	if phases <= 0 {
+		return nil, fmt.Errorf("phases must be greater than 0")
 	}
 	b := &ProgressBar{
-		progress:       0,
-		total:          t,
-		width:          20,
-		closed:         false,
+		phases:         phases,
+		barWidth:       20,
+		width:          terminalWidth(out),
 		fieldCharacter: "█",
 		emptyCharacter: "░",
 		prefix:         "|",
 		suffix:         "|",
 		out:            out,
-		err:            nil,
+		now:            time.Now,
 	}
+	b.phaseStart = b.now()
 	return b, nil
 }
 
+// StartPhase moves on to the next phase, which consists of total files, 0 if unknown.
+func (b *ProgressBar) StartPhase(name string, total int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed || b.err != nil {
+		return
+	}
+	b.phase++
+	b.phaseName = name
+	b.progress, b.total, b.lines = 0, max(total, 0), 0
+	b.current = ""
+	b.phaseStart = b.now()
+	b.render()
+}
+
+// Total sets the number of files of the current phase.
 func (b *ProgressBar) Total(t int) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
@@ -46,37 +86,151 @@ func (b *ProgressBar) Total(t int) {
 	}
 }
 
+// Tick advances the current phase by one file.
 func (b *ProgressBar) Tick() {
+	b.TickFile("", 0)
+}
+
+// TickFile advances the current phase by one file, which had the given number of lines.
+func (b *ProgressBar) TickFile(file string, lines int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
 	if b.closed || b.err != nil {
 		return
 	}
-	b.progress += 1
-	b.render()
-	if b.progress >= b.total {
-		b.Close()
+	b.progress++
+	b.lines += lines
+	if file != "" {
+		b.current = file
 	}
+	b.render()
+}
+
+// FileDone implements Progress.
+func (b *ProgressBar) FileDone(e FileEvent) {
+	b.TickFile(e.File, e.Lines)
+}
+
+// segment is a part of the rendered line; when the line does not fit the terminal,
+// segments of the lowest priority are dropped first.
+type segment struct {
+	text     string
+	priority int
 }
 
 func (b *ProgressBar) render() {
-	progress := float64(b.progress) / float64(b.total)
-	filled := int(progress * float64(b.width))
-	empty := b.width - filled
-	bar := strings.Repeat(b.fieldCharacter, filled) + strings.Repeat(b.emptyCharacter, empty)
-	percent := progress * 100
-	_, err := fmt.Fprintf(b.out, "\r%s%s%s %.1f%%", b.prefix, bar, b.suffix, percent)
+	elapsed := b.now().Sub(b.phaseStart)
+	var segments []segment
+	if b.phases > 1 {
+		segments = append(segments, segment{fmt.Sprintf("[%d/%d]", b.phase, b.phases), 5})
+	}
+	segments = append(segments, segment{b.phaseName, 6})
+	if b.total > 0 {
+		progress := min(float64(b.progress)/float64(b.total), 1)
+		filled := int(progress * float64(b.barWidth))
+		bar := strings.Repeat(b.fieldCharacter, filled) + strings.Repeat(b.emptyCharacter, b.barWidth-filled)
+		segments = append(segments,
+			segment{b.prefix + bar + b.suffix, 3},
+			segment{fmt.Sprintf("%.1f%%", progress*100), 6},
+			segment{fmt.Sprintf("%d/%d files", b.progress, b.total), 2})
+	}
+	if seconds := elapsed.Seconds(); b.progress > 0 && seconds > 0 {
+		segments = append(segments, segment{fmt.Sprintf("%.1f files/s %.0f lines/s",
+			float64(b.progress)/seconds, float64(b.lines)/seconds), 0})
+	}
+	segments = append(segments, segment{"elapsed " + formatDuration(elapsed), 1})
+	if b.total > 0 && b.progress > 0 && b.progress < b.total {
+		eta := elapsed / time.Duration(b.progress) * time.Duration(b.total-b.progress)
+		segments = append(segments, segment{"ETA " + formatDuration(eta), 4})
+	}
+
+	line := fitSegments(segments, b.width-1)
+	// The file name is only worth showing if a recognizable part of it fits.
+	if room := b.width - 2 - utf8.RuneCountInString(line); b.current != "" && room >= minFileWidth {
+		line += " " + truncateLeft(b.current, room)
+	}
+
+	text := truncateRight(line, b.width-1)
+	padding := b.width - 1 - utf8.RuneCountInString(text)
+	_, err := fmt.Fprintf(b.out, "\r%s%s", text, strings.Repeat(" ", max(padding, 0)))
 	if err != nil {
 		b.err = err
 	}
 }
 
+// fitSegments joins the segments with spaces, leaving out the least important ones
+// until the line is at most width runes long.
+func fitSegments(segments []segment, width int) string {
+	for {
+		texts := make([]string, 0, len(segments))
+		for _, s := range segments {
+			texts = append(texts, s.text)
+		}
+		line := strings.Join(texts, " ")
+		if utf8.RuneCountInString(line) <= width || len(segments) == 1 {
+			return line
+		}
+		lowest := 0
+		for i, s := range segments {
+			if s.priority < segments[lowest].priority {
+				lowest = i
+			}
+		}
+		segments = append(segments[:lowest:lowest], segments[lowest+1:]...)
+	}
+}
+
+// Close clears the bar; later calls to its methods have no effect.
 func (b *ProgressBar) Close() {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 	if !b.closed {
 		b.closed = true
-		_, err := fmt.Fprintf(b.out, "\r%s\r", strings.Repeat(" ", 10))
+		_, err := fmt.Fprintf(b.out, "\r%s\r", strings.Repeat(" ", b.width-1))
 		if err != nil {
 			b.err = err
 		}
 	}
 }
+
+func formatDuration(d time.Duration) string {
+	return d.Round(time.Second).String()
+}
+
+// truncateLeft keeps the end of s, which holds the most specific part of a path.
+func truncateLeft(s string, limit int) string {
+	runes := []rune(s)
+	if len(runes) <= limit {
+		return s
+	}
+	if limit <= 1 {
+		return ""
+	}
+	return "…" + string(runes[len(runes)-limit+1:])
+}
+
+func truncateRight(s string, limit int) string {
+	runes := []rune(s)
+	if len(runes) <= limit {
+		return s
+	}
+	return string(runes[:max(limit, 0)])
+}
+
+func terminalWidth(out io.Writer) int {
+	f, ok := out.(*os.File)
+	if !ok {
+		return defaultWidth
+	}
+	width, _, err := term.GetSize(int(f.Fd()))
+	if err != nil || width <= 1 {
+		return defaultWidth
+	}
+	return width
+}
+
+// IsTerminal reports whether f is a terminal; a progress bar written anywhere else
+// only garbles logs and pipes.
+func IsTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
@@ -0,0 +1,152 @@
+package progressbar
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRejectsNoPhases(t *testing.T) {
+	_, err := New(0, &bytes.Buffer{})
+	assert.Error(t, err)
+}
+
+func TestRender(t *testing.T) {
+	for _, tc := range []struct {
+		width    int
+		expected string
+	}{
+		{140, "[1/2] blaming |█████░░░░░░░░░░░░░░░| 25.0% 1/4 files 0.5 files/s 50 lines/s elapsed 2s ETA 6s " +
+			"internal/blame/blame.go"},
+		{80, "[1/2] blaming |█████░░░░░░░░░░░░░░░| 25.0% 1/4 files elapsed 2s ETA 6s"},
+		{40, "[1/2] blaming 25.0% ETA 6s …me/blame.go"},
+	} {
+		var out bytes.Buffer
+		b, err := New(2, &out)
+		require.NoError(t, err)
+		b.width = tc.width
+		now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+		b.now = func() time.Time { return now }
+
+		b.StartPhase("blaming", 4)
+		now = now.Add(2 * time.Second)
+		out.Reset()
+		b.TickFile("internal/blame/blame.go", 100)
+
+		line := strings.TrimPrefix(out.String(), "\r")
+		assert.Equal(t, tc.expected, strings.TrimRight(line, " "))
+		assert.Equal(t, tc.width-1, len([]rune(line)))
+	}
+}
+
+func TestRenderFitsWidth(t *testing.T) {
+	var out bytes.Buffer
+	b, err := New(1, &out)
+	require.NoError(t, err)
+	b.width = 60
+
+	b.StartPhase("blaming", 10)
+	out.Reset()
+	b.TickFile(strings.Repeat("dir/", 30)+"file.go", 10)
+
+	line := strings.TrimPrefix(out.String(), "\r")
+	assert.Equal(t, 59, len([]rune(line)))
+	assert.NotContains(t, line, "dir/dir/dir/dir/dir/dir/dir/dir/dir/dir/")
+}
+
+func TestConcurrentTicks(t *testing.T) {
+	var out bytes.Buffer
+	b, err := New(1, &out)
+	require.NoError(t, err)
+	b.StartPhase("blaming", 1000)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				b.Tick()
+				b.FileDone(FileEvent{File: "main.go", Lines: 2})
+				b.Total(1000)
+			}
+		}()
+	}
+	wg.Wait()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	assert.Equal(t, 1000, b.progress)
+	assert.Equal(t, 1000, b.lines)
+}
+
+func TestCloseWhileTicking(t *testing.T) {
+	var out bytes.Buffer
+	b, err := New(2, &out)
+	require.NoError(t, err)
+	b.StartPhase("blaming", 100)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				b.TickFile("main.go", 1)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			b.Close()
+		}()
+	}
+	wg.Wait()
+
+	out.Reset()
+	b.Tick()
+	b.StartPhase("aggregating", 0)
+	b.Close()
+	assert.Empty(t, out.String())
+}
+
+func TestCallbacks(t *testing.T) {
+	var phases []string
+	var files, lines atomic.Int64
+	closed := false
+	var p Progress = Callbacks{
+		OnPhase: func(name string, total int) { phases = append(phases, name) },
+		OnFile: func(e FileEvent) {
+			files.Add(1)
+			lines.Add(int64(e.Lines))
+		},
+		OnClose: func() { closed = true },
+	}
+
+	p.StartPhase("blaming", 20)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.FileDone(FileEvent{File: "main.go", Lines: 3})
+		}()
+	}
+	wg.Wait()
+	p.Close()
+
+	assert.Equal(t, []string{"blaming"}, phases)
+	assert.Equal(t, int64(20), files.Load())
+	assert.Equal(t, int64(60), lines.Load())
+	assert.True(t, closed)
+
+	var empty Progress = Callbacks{}
+	empty.StartPhase("blaming", 1)
+	empty.FileDone(FileEvent{})
+	empty.Close()
+}
@@ -0,0 +1,126 @@
+package report
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+func outputTabular(r *Report, out io.Writer) error {
+	if err := writeMetadataLines(r, "", out); err != nil {
+		return fmt.Errorf("failed to output tabular: %v", err)
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 1, ' ', 0)
+	_, err := fmt.Fprintln(w, strings.Join(ColumnHeaders(r.Columns), "\t"))
+	if err != nil {
+		return fmt.Errorf("failed to output tabular: %v", err)
+	}
+	for _, e := range r.Rows() {
+		_, err = fmt.Fprintln(w, strings.Join(ColumnValues(r.Columns, e), "\t"))
+		if err != nil {
+			return fmt.Errorf("failed to output tabular: %v", err)
+		}
+	}
+	err = w.Flush()
+	if err != nil {
+		return fmt.Errorf("failed to output tabular: %v", err)
+	}
+	return nil
+}
+
+func outputCSV(r *Report, out io.Writer) error {
+	if err := writeMetadataLines(r, "# ", out); err != nil {
+		return err
+	}
+	w := csv.NewWriter(out)
+	if err := w.Write(ColumnHeaders(r.Columns)); err != nil {
+		return fmt.Errorf("failed to output csv: %v", err)
+	}
+	for _, e := range r.Rows() {
+		if err := w.Write(ColumnValues(r.Columns, e)); err != nil {
+			return fmt.Errorf("failed to output csv: %v", err)
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func outputMarkdown(r *Report, out io.Writer) error {
+	if err := writeMetadataLines(r, "- ", out); err != nil {
+		return fmt.Errorf("failed to output markdown: %v", err)
+	}
+	separators := make([]string, 0, len(r.Columns))
+	for _, c := range r.Columns {
+		if c.Text {
+			separators = append(separators, "---")
+		} else {
+			separators = append(separators, "---:")
+		}
+	}
+	lines := []string{markdownRow(ColumnHeaders(r.Columns)), markdownRow(separators)}
+	for _, e := range r.Rows() {
+		lines = append(lines, markdownRow(ColumnValues(r.Columns, e)))
+	}
+	for _, line := range lines {
+		if _, err := fmt.Fprintln(out, line); err != nil {
+			return fmt.Errorf("failed to output markdown: %v", err)
+		}
+	}
+	return nil
+}
+
+var markdownEscaper = strings.NewReplacer(
+	"\\", "\\\\", "|", "\\|", "`", "\\`", "*", "\\*", "_", "\\_", "[", "\\[", "]", "\\]", "<", "&lt;", ">", "&gt;")
+
+func markdownRow(cells []string) string {
+	escaped := make([]string, 0, len(cells))
+	for _, c := range cells {
+		escaped = append(escaped, markdownEscaper.Replace(c))
+	}
+	return "| " + strings.Join(escaped, " | ") + " |"
+}
+
+// writeMetadataLines prints the report metadata as "Key: value" lines followed by an empty line.
+func writeMetadataLines(r *Report, prefix string, out io.Writer) error {
+	if !r.ShowMetadata {
+		return nil
+	}
+	for _, line := range MetadataLines(r.Metadata) {
+		if _, err := fmt.Fprintf(out, "%s%s\n", prefix, line); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintln(out)
+	return err
+}
+
+func outputJSON(r *Report, out io.Writer) error {
+	encoder := json.NewEncoder(out)
+	if m := r.envelope(); m != nil {
+		return encoder.Encode(struct {
+			Metadata     *Metadata      `json:"metadata"`
+			Contributors []*Contributor `json:"contributors"`
+		}{m, r.Contributors})
+	}
+	return encoder.Encode(r.Contributors)
+}
+
+func outputJSONLines(r *Report, out io.Writer) error {
+	encoder := json.NewEncoder(out)
+	if m := r.envelope(); m != nil {
+		if err := encoder.Encode(struct {
+			Metadata *Metadata `json:"metadata"`
+		}{m}); err != nil {
+			return err
+		}
+	}
+	for _, c := range r.Contributors {
+		if err := encoder.Encode(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
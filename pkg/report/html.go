@@ -0,0 +1,126 @@
+package report
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	chartBarHeight = 22
+	chartBarWidth  = 480
+	chartLabelSize = 200
+)
+
+//go:embed report.html.tmpl
+var htmlTemplateText string
+
+var htmlTemplate = template.Must(template.New("report").Parse(htmlTemplateText))
+
+type htmlPage struct {
+	Metadata []string
+	Headers  []htmlHeader
+	Rows     [][]htmlCell
+	Total    []htmlCell
+	Chart    htmlChart
+}
+
+type htmlHeader struct {
+	Title string
+	Text  bool
+}
+
+type htmlCell struct {
+	Value string
+	// Sort is the numeric sort key of numeric cells.
+	Sort string
+}
+
+type htmlChart struct {
+	Width, Height int
+	LabelWidth    int
+	Bars          []htmlBar
+}
+
+type htmlBar struct {
+	Name  string
+	Lines int
+	Y     int
+	Width int
+}
+
+func outputHTML(r *Report, out io.Writer) error {
+	page := htmlPage{
+		Rows: make([][]htmlCell, 0, len(r.Contributors)),
+	}
+	if r.ShowMetadata {
+		page.Metadata = MetadataLines(r.Metadata)
+	}
+	for _, c := range r.Columns {
+		page.Headers = append(page.Headers, htmlHeader{Title: c.Header, Text: c.Text})
+	}
+	for _, e := range r.Contributors {
+		page.Rows = append(page.Rows, htmlCells(r.Columns, e))
+	}
+	if r.Total != nil {
+		page.Total = htmlCells(r.Columns, r.Total)
+	}
+	page.Chart = lineChart(r.Contributors)
+
+	if err := htmlTemplate.Execute(out, page); err != nil {
+		return fmt.Errorf("failed to output html: %v", err)
+	}
+	return nil
+}
+
+func htmlCells(columns []Column, contributor *Contributor) []htmlCell {
+	cells := make([]htmlCell, 0, len(columns))
+	for _, c := range columns {
+		value := c.Value(contributor)
+		cell := htmlCell{Value: value}
+		if !c.Text {
+			if _, err := strconv.ParseFloat(value, 64); err == nil {
+				cell.Sort = value
+			}
+		}
+		cells = append(cells, cell)
+	}
+	return cells
+}
+
+// lineChart draws one horizontal bar per contributor, scaled to the largest line count.
+func lineChart(contributors []*Contributor) htmlChart {
+	chart := htmlChart{
+		Width:      chartLabelSize + chartBarWidth + 60,
+		Height:     len(contributors)*chartBarHeight + 4,
+		LabelWidth: chartLabelSize,
+	}
+	maxLines := 0
+	for _, c := range contributors {
+		maxLines = max(maxLines, c.Lines)
+	}
+	for i, c := range contributors {
+		width := 0
+		if maxLines > 0 {
+			width = c.Lines * chartBarWidth / maxLines
+		}
+		chart.Bars = append(chart.Bars, htmlBar{
+			Name:  truncateLabel(c.Name, 28),
+			Lines: c.Lines,
+			Y:     i*chartBarHeight + 2,
+			Width: width,
+		})
+	}
+	return chart
+}
+
+func truncateLabel(s string, limit int) string {
+	runes := []rune(s)
+	if len(runes) <= limit {
+		return s
+	}
+	return strings.TrimSpace(string(runes[:limit-1])) + "…"
+}
@@ -0,0 +1,72 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+type metric struct {
+	name  string
+	help  string
+	value func(*Contributor) (int, bool)
+}
+
+var metrics = []metric{
+	// With churn the lines are added plus deleted, which gitfame_lines_added and
+	// gitfame_lines_deleted already give.
+	{"gitfame_lines", "Lines attributed to the contributor.", func(c *Contributor) (int, bool) {
+		return c.Lines, c.Churn == nil
+	}},
+	{"gitfame_commits", "Commits of the contributor.", func(c *Contributor) (int, bool) {
+		return c.Commits, true
+	}},
+	{"gitfame_files", "Files the contributor touched.", func(c *Contributor) (int, bool) {
+		return c.Files, true
+	}},
+	{"gitfame_lines_added", "Lines added by the contributor.", func(c *Contributor) (int, bool) {
+		if c.Churn == nil {
+			return 0, false
+		}
+		return c.Churn.Added, true
+	}},
+	{"gitfame_lines_deleted", "Lines deleted by the contributor.", func(c *Contributor) (int, bool) {
+		if c.Churn == nil {
+			return 0, false
+		}
+		return c.Churn.Deleted, true
+	}},
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// outputOpenMetrics writes one gauge family per metric in the OpenMetrics text format,
+// labelled with the contributor and the repository directory name.
+func outputOpenMetrics(r *Report, out io.Writer) error {
+	repo := ""
+	if r.Metadata != nil && r.Metadata.Repository != "" {
+		repo = filepath.Base(r.Metadata.Repository)
+	}
+	for _, m := range metrics {
+		header := false
+		for _, c := range r.Contributors {
+			value, ok := m.value(c)
+			if !ok {
+				continue
+			}
+			if !header {
+				if _, err := fmt.Fprintf(out, "# TYPE %s gauge\n# HELP %s %s\n", m.name, m.name, m.help); err != nil {
+					return err
+				}
+				header = true
+			}
+			if _, err := fmt.Fprintf(out, "%s{author=\"%s\",repo=\"%s\"} %d\n",
+				m.name, labelEscaper.Replace(c.Name), labelEscaper.Replace(repo), value); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := fmt.Fprintln(out, "# EOF")
+	return err
+}
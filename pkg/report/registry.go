@@ -0,0 +1,154 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Formatter renders a report in one output format.
+type Formatter interface {
+	Format(r *Report, out io.Writer) error
+}
+
+// FormatterFunc adapts a function to the Formatter interface.
+type FormatterFunc func(r *Report, out io.Writer) error
+
+func (f FormatterFunc) Format(r *Report, out io.Writer) error {
+	return f(r, out)
+}
+
+type registration struct {
+	name        string
+	description string
+	formatter   Formatter
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   []registration
+	extensions = make(map[string]string)
+)
+
+func init() {
+	Register("tabular", "aligned plain text table", FormatterFunc(outputTabular))
+	Register("csv", "comma-separated values", FormatterFunc(outputCSV))
+	Register("json", "JSON array of contributors", FormatterFunc(outputJSON))
+	Register("json-lines", "one JSON object per contributor", FormatterFunc(outputJSONLines))
+	Register("markdown", "GitHub-flavoured markdown table", FormatterFunc(outputMarkdown))
+	Register("html", "self-contained page with sortable table and chart", FormatterFunc(outputHTML))
+	Register("openmetrics", "OpenMetrics gauges for Prometheus", FormatterFunc(outputOpenMetrics))
+	Register("template", "Go text/template given by --template or --template-file", FormatterFunc(outputTemplate))
+
+	RegisterExtension(".txt", "tabular")
+	RegisterExtension(".csv", "csv")
+	RegisterExtension(".json", "json")
+	RegisterExtension(".jsonl", "json-lines")
+	RegisterExtension(".ndjson", "json-lines")
+	RegisterExtension(".md", "markdown")
+	RegisterExtension(".html", "html")
+	RegisterExtension(".htm", "html")
+	RegisterExtension(".prom", "openmetrics")
+}
+
+// Register adds a formatter under name, replacing a previously registered one.
+// It is meant to be called from init functions, before gitfame parses its flags.
+func Register(name, description string, f Formatter) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	for i, r := range registry {
+		if r.name == name {
+			registry[i] = registration{name: name, description: description, formatter: f}
+			return
+		}
+	}
+	registry = append(registry, registration{name: name, description: description, formatter: f})
+}
+
+// Lookup returns the formatter registered under name.
+func Lookup(name string) (Formatter, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	for _, r := range registry {
+		if r.name == name {
+			return r.formatter, true
+		}
+	}
+	return nil, false
+}
+
+// RegisterExtension makes files with the extension (including the dot) written in format.
+func RegisterExtension(ext, format string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	extensions[strings.ToLower(ext)] = format
+}
+
+// FormatForPath returns the format registered for the extension of path.
+func FormatForPath(path string) (string, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	format, ok := extensions[strings.ToLower(filepath.Ext(path))]
+	return format, ok
+}
+
+// Names returns the registered format names in registration order.
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for _, r := range registry {
+		names = append(names, r.name)
+	}
+	return names
+}
+
+// Describe returns the description a format was registered with.
+func Describe(name string) string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	for _, r := range registry {
+		if r.name == name {
+			return r.description
+		}
+	}
+	return ""
+}
+
+// Write renders the report with the formatter registered under format.
+func Write(r *Report, format string, out io.Writer) error {
+	formatter, ok := Lookup(format)
+	if !ok {
+		return fmt.Errorf("unsupported format: %s", format)
+	}
+	return formatter.Format(r, out)
+}
+
+// WriteFile renders the report to path atomically: the output is written to a temporary
+// file in the same directory, which then replaces path, so readers never see a partial report.
+func WriteFile(r *Report, format, path string) (err error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if err := Write(r, format, tmp); err != nil {
+		return err
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
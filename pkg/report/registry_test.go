@@ -0,0 +1,97 @@
+package report
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testReport() *Report {
+	return &Report{
+		Metadata: &Metadata{},
+		Contributors: []*Contributor{
+			{Name: "Alice", Lines: 10, Commits: 2, Files: 1},
+			{Name: "Bob", Lines: 5, Commits: 1, Files: 1},
+		},
+	}
+}
+
+func namesFormatter(separator string) Formatter {
+	return FormatterFunc(func(r *Report, out io.Writer) error {
+		names := make([]string, 0, len(r.Contributors))
+		for _, c := range r.Contributors {
+			names = append(names, c.Name)
+		}
+		_, err := fmt.Fprintln(out, strings.Join(names, separator))
+		return err
+	})
+}
+
+func TestRegisterCustomFormat(t *testing.T) {
+	Register("test-names", "contributor names", namesFormatter(","))
+	RegisterExtension(".Names", "test-names")
+
+	var out strings.Builder
+	require.NoError(t, Write(testReport(), "test-names", &out))
+	assert.Equal(t, "Alice,Bob\n", out.String())
+
+	format, ok := FormatForPath("out/report.names")
+	assert.True(t, ok)
+	assert.Equal(t, "test-names", format)
+	assert.Contains(t, Names(), "test-names")
+	assert.Equal(t, "contributor names", Describe("test-names"))
+
+	_, ok = FormatForPath("report.unknown")
+	assert.False(t, ok)
+}
+
+func TestRegisterReplaces(t *testing.T) {
+	Register("test-replace", "first", namesFormatter(","))
+	Register("test-replace", "second", namesFormatter(" "))
+
+	var out strings.Builder
+	require.NoError(t, Write(testReport(), "test-replace", &out))
+	assert.Equal(t, "Alice Bob\n", out.String())
+	assert.Equal(t, "second", Describe("test-replace"))
+
+	count := 0
+	for _, name := range Names() {
+		if name == "test-replace" {
+			count++
+		}
+	}
+	assert.Equal(t, 1, count)
+}
+
+func TestWriteUnknownFormat(t *testing.T) {
+	_, ok := Lookup("no-such-format")
+	assert.False(t, ok)
+	assert.Error(t, Write(testReport(), "no-such-format", io.Discard))
+}
+
+func TestWriteFile(t *testing.T) {
+	Register("test-file", "contributor names", namesFormatter(","))
+	Register("test-failing", "always fails", FormatterFunc(func(r *Report, out io.Writer) error {
+		return errors.New("failed")
+	}))
+	dir := t.TempDir()
+	path := filepath.Join(dir, "report.txt")
+
+	require.NoError(t, WriteFile(testReport(), "test-file", path))
+	// A failed write keeps the previous report and leaves no temporary file behind.
+	assert.Error(t, WriteFile(testReport(), "test-failing", path))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "Alice,Bob\n", string(data))
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
@@ -0,0 +1,165 @@
+// Package report holds the gitfame result model and the formatters that render it.
+package report
+
+import (
+	"text/template"
+	"time"
+)
+
+type Contributor struct {
+	Name              string     `json:"name"`
+	Lines             int        `json:"lines"`
+	Commits           int        `json:"commits"`
+	SurvivingCommits  int        `json:"surviving_commits,omitempty"`
+	Files             int        `json:"files"`
+	Emails            []string   `json:"emails"`
+	FirstContribution time.Time  `json:"first_contribution,omitzero"`
+	LastContribution  time.Time  `json:"last_contribution,omitzero"`
+	Age               *LineAge   `json:"age,omitempty"`
+	Churn             *LineChurn `json:"churn,omitempty"`
+	Share             *Share     `json:"share,omitempty"`
+}
+
+// LineAge buckets the surviving lines of a contributor by the age of their commits.
+type LineAge struct {
+	UnderMonth      int `json:"under_1m"`
+	UnderHalfYear   int `json:"under_6m"`
+	UnderYear       int `json:"under_1y"`
+	UnderThreeYears int `json:"under_3y"`
+	Older           int `json:"older"`
+	MedianDays      int `json:"median_days"`
+}
+
+type LineChurn struct {
+	Added   int `json:"added"`
+	Deleted int `json:"deleted"`
+}
+
+// Share holds percentages of the report totals.
+type Share struct {
+	Lines   float64 `json:"lines"`
+	Commits float64 `json:"commits"`
+	Files   float64 `json:"files"`
+}
+
+type Report struct {
+	Metadata     *Metadata
+	Contributors []*Contributor
+
+	// Columns are the columns selected for table-like formats.
+	Columns []Column
+	// ShowMetadata prints the metadata above the table in text formats.
+	ShowMetadata bool
+	// Total is printed as the last row of table-like formats when set.
+	Total *Contributor
+	// Template renders the report in the template format.
+	Template *template.Template
+}
+
+// SchemaVersion is the version of the JSON envelope described by gitfame.schema.json.
+// It changes whenever a field is removed or changes its meaning.
+const SchemaVersion = 1
+
+// Filters are the file and author restrictions a report was computed with.
+type Filters struct {
+	Extensions     []string `json:"extensions"`
+	Languages      []string `json:"languages"`
+	Exclude        []string `json:"exclude"`
+	RestrictTo     []string `json:"restrict_to"`
+	ExcludeAuthors []string `json:"exclude_authors"`
+	OnlyAuthors    []string `json:"only_authors"`
+}
+
+// Metadata describes the run that produced a report.
+type Metadata struct {
+	SchemaVersion int       `json:"schema_version"`
+	Version       string    `json:"gitfame_version"`
+	Repository    string    `json:"repository"`
+	Revision      string    `json:"revision"`
+	Commit        string    `json:"commit"`
+	At            time.Time `json:"at,omitzero"`
+	Filters       Filters   `json:"filters"`
+	// Files is the number of files that passed the filters, SkippedFiles those of them
+	// that could not be analyzed.
+	Files           int       `json:"files"`
+	SkippedFiles    []string  `json:"skipped_files"`
+	DurationSeconds float64   `json:"duration_seconds"`
+	GeneratedAt     time.Time `json:"generated_at"`
+	Totals          *Totals   `json:"totals,omitempty"`
+}
+
+type Totals struct {
+	Contributors int `json:"contributors"`
+	Lines        int `json:"lines"`
+	Commits      int `json:"commits"`
+	Files        int `json:"files"`
+	Added        int `json:"added,omitempty"`
+	Deleted      int `json:"deleted,omitempty"`
+}
+
+func NewTotals(total *Contributor, contributors int) *Totals {
+	t := &Totals{
+		Contributors: contributors,
+		Lines:        total.Lines,
+		Commits:      total.Commits,
+		Files:        total.Files,
+	}
+	if total.Churn != nil {
+		t.Added = total.Churn.Added
+		t.Deleted = total.Churn.Deleted
+	}
+	return t
+}
+
+// envelope returns the metadata wrapped around JSON output: present with --metadata
+// or --totals, and carrying the totals only with the latter.
+func (r *Report) envelope() *Metadata {
+	if r.Metadata == nil || (!r.ShowMetadata && r.Total == nil) {
+		return nil
+	}
+	m := *r.Metadata
+	if r.Total == nil {
+		m.Totals = nil
+	}
+	return &m
+}
+
+// Rows returns the contributors followed by the totals row, if requested.
+func (r *Report) Rows() []*Contributor {
+	if r.Total == nil {
+		return r.Contributors
+	}
+	return append(r.Contributors[:len(r.Contributors):len(r.Contributors)], r.Total)
+}
+
+type Column struct {
+	Header string
+	// Text columns are left-aligned in markdown and sorted alphabetically in html.
+	Text  bool
+	Value func(*Contributor) string
+}
+
+func ColumnHeaders(columns []Column) []string {
+	headers := make([]string, 0, len(columns))
+	for _, c := range columns {
+		headers = append(headers, c.Header)
+	}
+	return headers
+}
+
+func ColumnValues(columns []Column, contributor *Contributor) []string {
+	values := make([]string, 0, len(columns))
+	for _, c := range columns {
+		values = append(values, c.Value(contributor))
+	}
+	return values
+}
+
+// MetadataLines returns the metadata as "Key: value" lines.
+func MetadataLines(m *Metadata) []string {
+	lines := []string{"Revision: " + m.Revision, "Commit: " + m.Commit}
+	if !m.At.IsZero() {
+		lines = append(lines, "At: "+m.At.Format(time.RFC3339))
+	}
+	return lines
+}
@@ -0,0 +1,65 @@
+package report
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// TemplateData is the value templates of the template format are executed with.
+//
+//	.Contributors  the report rows, each a *Contributor (.Name, .Lines, .Commits, .Files,
+//	               .Emails, .FirstContribution, .LastContribution and, depending on
+//	               the flags, .Age, .Churn and .Share)
+//	.Totals        sums over all contributors (.Contributors, .Lines, .Commits, .Files,
+//	               .Added, .Deleted), with or without --totals
+//	.Revision      the revision or range as given
+//	.Commit        the commit hash the revision resolved to
+//	.At            the --at time, zero if not set
+//	.Filters       the file and author filters (.Extensions, .Languages, .Exclude,
+//	               .RestrictTo, .ExcludeAuthors, .OnlyAuthors)
+type TemplateData struct {
+	Contributors []*Contributor
+	Totals       *Totals
+	Revision     string
+	Commit       string
+	At           time.Time
+	Filters      Filters
+}
+
+// templateFuncs are available to templates in addition to the text/template builtins.
+var templateFuncs = template.FuncMap{
+	"join":  strings.Join,
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"date": func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.UTC().Format("2006-01-02")
+	},
+}
+
+// ParseTemplate parses text as a template for the template format.
+func ParseTemplate(name, text string) (*template.Template, error) {
+	return template.New(name).Funcs(templateFuncs).Parse(text)
+}
+
+func outputTemplate(r *Report, out io.Writer) error {
+	if r.Template == nil {
+		return errors.New("template format requires a template")
+	}
+	data := TemplateData{
+		Contributors: r.Contributors,
+	}
+	if r.Metadata != nil {
+		data.Filters = r.Metadata.Filters
+		data.Totals = r.Metadata.Totals
+		data.Revision = r.Metadata.Revision
+		data.Commit = r.Metadata.Commit
+		data.At = r.Metadata.At
+	}
+	return r.Template.Execute(out, data)
+}
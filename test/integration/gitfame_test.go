@@ -2,23 +2,37 @@ package integration
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"github.com/santhosh-tekuri/jsonschema/v5"
 	"github.com/stretchr/testify/assert"
 	"gopkg.in/yaml.v2"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
 const (
 	mainProjectPath = "github.com/GlebMoskalev/gitfame/cmd/gitfame"
 	nameBinary      = "gitfametest"
+	// testDirPlaceholder in args is replaced with the absolute path of the test case directory.
+	testDirPlaceholder = "{test_dir}"
+	// repositoryPlaceholder replaces the path of the cloned bundle in the output.
+	repositoryPlaceholder = "{repository}"
+	// outDirPlaceholder in args is replaced with an empty directory for --output files.
+	outDirPlaceholder = "{out_dir}"
+	// anyValue in expected JSON matches any value, e.g. durations and timestamps.
+	anyValue = "*"
+	// schemaPath is the published JSON Schema every json output is validated against.
+	schemaPath = "../../pkg/report/gitfame.schema.json"
 )
 
 type TestCase struct {
 	*TestDescription
 	Expected []byte
+	Dir      string
 }
 
 type TestDescription struct {
@@ -27,6 +41,19 @@ type TestDescription struct {
 	Bundle string   `yaml:"bundle"`
 	Error  bool     `yaml:"error,omitempty"`
 	Format string   `yaml:"format,omitempty"`
+	// Files are written by the command to {out_dir} and compared with the files of the
+	// same name in the test case directory.
+	Files []string `yaml:"files,omitempty"`
+	// Stderr must be contained in the diagnostics the command writes to stderr.
+	Stderr string `yaml:"stderr,omitempty"`
+	// Env holds extra environment variables; XDG_CONFIG_HOME defaults to an empty directory.
+	Env map[string]string `yaml:"env,omitempty"`
+	// RepoConfig is a file of the test case directory installed as .gitfame.yaml in the
+	// repository root for the duration of the test.
+	RepoConfig string `yaml:"repo_config,omitempty"`
+	// UserConfig is a file of the test case directory installed as the user config. If it
+	// contains {repository}, that is replaced with the clone and --repository is not passed.
+	UserConfig string `yaml:"user_config,omitempty"`
 }
 
 func TestGitFame(t *testing.T) {
@@ -37,6 +64,11 @@ func TestGitFame(t *testing.T) {
 		t.Fatalf("failed to build binary: %v", err)
 	}
 
+	schema, err := jsonschema.Compile(schemaPath)
+	if err != nil {
+		t.Fatalf("failed to compile JSON schema: %v", err)
+	}
+
 	bundleNameToPath := getBundleNamesToPath(t, tempDir)
 	for _, ts := range readTestCases(t) {
 		t.Run(ts.Name, func(t *testing.T) {
@@ -44,14 +76,41 @@ func TestGitFame(t *testing.T) {
 			if !ok {
 				t.Fatalf("failed to get bundle %q", ts.Bundle)
 			}
-			args := []string{"--repository", bundlePath}
-			args = append(args, ts.Args...)
+			outDir := t.TempDir()
+			configHome := t.TempDir()
+			var args []string
+			if ts.UserConfig == "" || !installUserConfig(t, filepath.Join(ts.Dir, ts.UserConfig), bundlePath, configHome) {
+				args = append(args, "--repository", bundlePath)
+			}
+			for _, arg := range ts.Args {
+				arg = strings.ReplaceAll(arg, testDirPlaceholder, ts.Dir)
+				args = append(args, strings.ReplaceAll(arg, outDirPlaceholder, outDir))
+			}
+			if ts.RepoConfig != "" {
+				installRepoConfig(t, filepath.Join(ts.Dir, ts.RepoConfig), bundlePath)
+			}
 			cmd := exec.Command(fmt.Sprintf("./%s", nameBinary), args...)
 			cmd.Dir = tempDir
+			cmd.Env = append(os.Environ(), "XDG_CONFIG_HOME="+configHome)
+			for k, v := range ts.Env {
+				cmd.Env = append(cmd.Env, k+"="+strings.ReplaceAll(v, testDirPlaceholder, ts.Dir))
+			}
+			var stderr bytes.Buffer
+			cmd.Stderr = &stderr
 			output, err := cmd.Output()
+			// Paths of the clone and the test case vary between runs.
+			output = bytes.ReplaceAll(output, []byte(bundlePath), []byte(repositoryPlaceholder))
+			output = bytes.ReplaceAll(output, []byte(ts.Dir), []byte(testDirPlaceholder))
+			assert.Contains(t, stderr.String(), ts.Stderr)
 			if !ts.Error {
 				assert.NoError(t, err)
+				if ts.Format == "json" {
+					validateSchema(t, schema, output)
+				}
 				equalResult(t, ts.Expected, output, ts.Format)
+				for _, name := range ts.Files {
+					equalFile(t, filepath.Join(ts.Dir, name), filepath.Join(outDir, name))
+				}
 			} else {
 				assert.Error(t, err)
 			}
@@ -65,19 +124,125 @@ func equalResult(t *testing.T, expected, actual []byte, format string) {
 	switch format {
 	case "json":
 		fmt.Println()
-		assert.JSONEq(t, string(expected), string(actual))
+		equalJSON(t, expected, actual)
 	case "json-lines":
 		expectedLines := bytes.Split(bytes.TrimSpace(expected), []byte("\n"))
 		actualLines := bytes.Split(bytes.TrimSpace(actual), []byte("\n"))
 		assert.Equal(t, len(expectedLines), len(actualLines))
 		for i, l := range expectedLines {
-			assert.JSONEq(t, string(l), string(actualLines[i]))
+			if i < len(actualLines) {
+				equalJSON(t, l, actualLines[i])
+			}
 		}
 	default:
 		assert.Equal(t, string(expected), string(actual))
 	}
 }
 
+func installRepoConfig(t *testing.T, source, repository string) {
+	t.Helper()
+
+	data, err := os.ReadFile(source)
+	if err != nil {
+		t.Fatalf("failed to read repo config %q: %v", source, err)
+	}
+	path := filepath.Join(repository, ".gitfame.yaml")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("failed to install repo config: %v", err)
+	}
+	t.Cleanup(func() { os.Remove(path) })
+}
+
+// installUserConfig installs the user config in configHome and reports whether it
+// chooses the repository.
+func installUserConfig(t *testing.T, source, repository, configHome string) bool {
+	t.Helper()
+
+	data, err := os.ReadFile(source)
+	if err != nil {
+		t.Fatalf("failed to read user config %q: %v", source, err)
+	}
+	setsRepository := bytes.Contains(data, []byte(repositoryPlaceholder))
+	data = bytes.ReplaceAll(data, []byte(repositoryPlaceholder), []byte(repository))
+	dir := filepath.Join(configHome, "gitfame")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("failed to create user config directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), data, 0o644); err != nil {
+		t.Fatalf("failed to install user config: %v", err)
+	}
+	return setsRepository
+}
+
+func equalFile(t *testing.T, expectedPath, actualPath string) {
+	t.Helper()
+
+	expected, err := os.ReadFile(expectedPath)
+	if err != nil {
+		t.Fatalf("failed to read expected file %q: %v", expectedPath, err)
+	}
+	actual, err := os.ReadFile(actualPath)
+	if !assert.NoError(t, err) {
+		return
+	}
+	format := ""
+	switch filepath.Ext(actualPath) {
+	case ".json":
+		format = "json"
+	case ".jsonl":
+		format = "json-lines"
+	}
+	equalResult(t, expected, actual, format)
+}
+
+// equalJSON compares JSON documents, letting anyValue in expected match any present value.
+func equalJSON(t *testing.T, expected, actual []byte) {
+	t.Helper()
+
+	var e, a any
+	if err := json.Unmarshal(expected, &e); err != nil {
+		t.Fatalf("failed to unmarshal expected JSON: %v", err)
+	}
+	if err := json.Unmarshal(actual, &a); err != nil {
+		t.Fatalf("failed to unmarshal actual JSON %q: %v", actual, err)
+	}
+	assert.Equal(t, e, replaceAnyValues(e, a))
+}
+
+func replaceAnyValues(expected, actual any) any {
+	switch e := expected.(type) {
+	case string:
+		if e == anyValue && actual != nil {
+			return anyValue
+		}
+	case map[string]any:
+		if a, ok := actual.(map[string]any); ok {
+			for k, v := range e {
+				if av, ok := a[k]; ok {
+					a[k] = replaceAnyValues(v, av)
+				}
+			}
+		}
+	case []any:
+		if a, ok := actual.([]any); ok {
+			for i := 0; i < len(e) && i < len(a); i++ {
+				a[i] = replaceAnyValues(e[i], a[i])
+			}
+		}
+	}
+	return actual
+}
+
+func validateSchema(t *testing.T, schema *jsonschema.Schema, output []byte) {
+	t.Helper()
+
+	var v any
+	if err := json.Unmarshal(output, &v); err != nil {
+		t.Fatalf("failed to unmarshal output: %v", err)
+	}
+	assert.NoError(t, schema.Validate(v))
+}
+
 func readTestCases(t *testing.T) []*TestCase {
 	testsDir := "./testdata/tests"
 	entries, err := os.ReadDir(testsDir)
@@ -101,9 +266,14 @@ func readTestCase(t *testing.T, path string) *TestCase {
 	if err != nil {
 		t.Fatalf("failed to read expected.out at %q: %v", path, err)
 	}
+	dir, err := filepath.Abs(path)
+	if err != nil {
+		t.Fatalf("failed to get absolute path of %q: %v", path, err)
+	}
 	return &TestCase{
 		TestDescription: desc,
 		Expected:        expected,
+		Dir:             dir,
 	}
 }
 